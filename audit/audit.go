@@ -0,0 +1,106 @@
+// Package audit records who changed what. Entries live in audit_log, which is
+// RANGE-partitioned by month so retention is a matter of dropping partitions.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+)
+
+type Entry struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id"`
+	Entity    string          `json:"entity"`
+	EntityID  int64           `json:"entity_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Record writes an entry with before and after marshaled to JSON; either may
+// be nil (create has no before, delete has no after). Pass the transaction
+// performing the change.
+func Record(ctx context.Context, ex db.Execer, e Entry, before, after interface{}) error {
+	beforeJSON, err := marshalOrNull(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalOrNull(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.ExecContext(ctx, `
+		INSERT INTO audit_log (actor, request_id, entity, entity_id, action, before_json, after_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.Actor, e.RequestID, e.Entity, e.EntityID, e.Action, beforeJSON, afterJSON)
+	return err
+}
+
+func marshalOrNull(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// Filter selects entries. From and To are required so that every query can be
+// pruned to the months it covers.
+type Filter struct {
+	Entity   string
+	EntityID int64
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// List returns matching entries, newest first.
+func List(ctx context.Context, q db.Queryer, f Filter) ([]Entry, error) {
+	conds := []string{"created_at >= ?", "created_at < ?"}
+	args := []interface{}{f.From, f.To}
+	if f.Entity != "" {
+		conds = append(conds, "entity = ?")
+		args = append(args, f.Entity)
+	}
+	if f.EntityID != 0 {
+		conds = append(conds, "entity_id = ?")
+		args = append(args, f.EntityID)
+	}
+	args = append(args, f.Limit)
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, actor, request_id, entity, entity_id, action, before_json, after_json, created_at
+		FROM audit_log WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY created_at DESC, id DESC LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.Actor, &e.RequestID, &e.Entity, &e.EntityID, &e.Action, &before, &after, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Before = jsonOrNull(before)
+		e.After = jsonOrNull(after)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func jsonOrNull(b []byte) json.RawMessage {
+	if b == nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
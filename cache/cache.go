@@ -0,0 +1,48 @@
+package cache
+
+import "sync/atomic"
+
+// Cache is a key/value cache used in front of single-entity lookups.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Delete(key string)
+	Stats() Stats
+}
+
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// Noop never stores anything. Every Get is counted as a miss, which makes it
+// useful as a baseline when measuring database latency.
+type Noop struct {
+	misses counter
+}
+
+func (n *Noop) Get(key string) (interface{}, bool) {
+	n.misses.inc()
+	return nil, false
+}
+
+func (n *Noop) Set(key string, value interface{}) {}
+
+func (n *Noop) Delete(key string) {}
+
+func (n *Noop) Stats() Stats {
+	return Stats{Misses: n.misses.load()}
+}
+
+type counter struct {
+	n uint64
+}
+
+func (c *counter) inc() {
+	atomic.AddUint64(&c.n, 1)
+}
+
+func (c *counter) load() uint64 {
+	return atomic.LoadUint64(&c.n)
+}
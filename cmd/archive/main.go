@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/internal/schema"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+func main() {
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
+
+	table := flag.String("table", "books_range_year", "Partitioned table")
+	partition := flag.String("partition", "", "Partition to archive or restore (e.g. p2020)")
+	archive := flag.String("archive", "", "Archive table name (default: <table>_<partition>)")
+	compress := flag.Bool("compress", false, "Convert the archive table to ROW_FORMAT=COMPRESSED after the exchange")
+	restore := flag.Bool("restore", false, "Swap the archive table back into the (empty) partition")
+	keep := flag.Bool("keep", false, "Keep the emptied archive table after a restore")
+	list := flag.Bool("list", false, "List partitions of the table and exit")
+
+	flag.Parse()
+
+	// Swapping out a HASH or KEY partition makes no sense: new rows keep
+	// hashing into it
+	if _, err := variant.Default.Require(*table, variant.Range, variant.List); err != nil {
+		log.Fatalf("Invalid -table: %v", err)
+	}
+
+	cfg := dbFlags.MustLoad()
+
+	db, err := cfg.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	log.Println("Connected to database")
+
+	if *list {
+		if err := listPartitions(db, *table); err != nil {
+			log.Fatalf("Failed to list partitions: %v", err)
+		}
+		return
+	}
+
+	if *partition == "" {
+		log.Fatal("-partition is required")
+	}
+	if *archive == "" {
+		*archive = *table + "_" + *partition
+	}
+
+	if *restore {
+		err = restorePartition(db, *table, *partition, *archive, *keep)
+	} else {
+		err = archivePartition(db, *table, *partition, *archive, *compress)
+	}
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+}
+
+// archivePartition swaps a partition out into a standalone table. The
+// exchange is a metadata-only operation, so it takes the same time whether
+// the partition holds a thousand rows or a hundred million.
+func archivePartition(db *sql.DB, table, partition, archive string, compress bool) error {
+	if err := requirePartition(db, table, partition); err != nil {
+		return err
+	}
+	if exists, err := schema.TableExists(db, archive); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("archive table %s already exists", archive)
+	}
+
+	before, err := schema.CountPartition(db, table, partition)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s PARTITION (%s): %d rows", table, partition, before)
+
+	// EXCHANGE PARTITION needs a non-partitioned table with identical columns and indexes
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %s LIKE %s", schema.Quote(archive), schema.Quote(table))); err != nil {
+		return fmt.Errorf("create archive table: %w", err)
+	}
+	err = func() error {
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s REMOVE PARTITIONING", schema.Quote(archive))); err != nil {
+			return fmt.Errorf("remove partitioning from archive table: %w", err)
+		}
+		return exchange(db, table, partition, archive)
+	}()
+	if err != nil {
+		// The archive table is still empty; drop it so a rerun can start over
+		if _, dropErr := db.Exec("DROP TABLE " + schema.Quote(archive)); dropErr != nil {
+			log.Printf("Warning: failed to drop %s: %v", archive, dropErr)
+		}
+		return err
+	}
+
+	if err := verifyCounts(db, table, partition, archive, 0, before); err != nil {
+		return err
+	}
+
+	if compress {
+		log.Printf("Compressing %s...", archive)
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8", schema.Quote(archive))); err != nil {
+			return fmt.Errorf("compress archive table: %w", err)
+		}
+		if err := verifyCounts(db, table, partition, archive, 0, before); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Archived %s PARTITION (%s) into %s (%d rows)", table, partition, archive, before)
+	return nil
+}
+
+// restorePartition swaps an archive table back into its partition. The
+// partition must be empty, otherwise its rows would end up in the archive.
+func restorePartition(db *sql.DB, table, partition, archive string, keep bool) error {
+	if err := requirePartition(db, table, partition); err != nil {
+		return err
+	}
+	if exists, err := schema.TableExists(db, archive); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("archive table %s does not exist", archive)
+	}
+
+	current, err := schema.CountPartition(db, table, partition)
+	if err != nil {
+		return err
+	}
+	if current != 0 {
+		return fmt.Errorf("%s PARTITION (%s) is not empty (%d rows)", table, partition, current)
+	}
+
+	archived, err := schema.CountTable(db, archive)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s: %d rows", archive, archived)
+
+	// Row formats must match for the exchange, so undo -compress first
+	var tableFormat, archiveFormat string
+	if tableFormat, err = rowFormat(db, table); err != nil {
+		return err
+	}
+	if archiveFormat, err = rowFormat(db, archive); err != nil {
+		return err
+	}
+	if !strings.EqualFold(tableFormat, archiveFormat) {
+		log.Printf("Converting %s from %s to %s...", archive, archiveFormat, tableFormat)
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ROW_FORMAT=%s KEY_BLOCK_SIZE=0", schema.Quote(archive), tableFormat)); err != nil {
+			return fmt.Errorf("convert archive row format: %w", err)
+		}
+	}
+
+	// WITH VALIDATION (the default) rejects rows outside the partition's range
+	if err := exchange(db, table, partition, archive); err != nil {
+		return err
+	}
+
+	if err := verifyCounts(db, table, partition, archive, archived, 0); err != nil {
+		return err
+	}
+
+	if !keep {
+		if _, err := db.Exec("DROP TABLE " + schema.Quote(archive)); err != nil {
+			return fmt.Errorf("drop archive table: %w", err)
+		}
+	}
+
+	log.Printf("Restored %s into %s PARTITION (%s) (%d rows)", archive, table, partition, archived)
+	return nil
+}
+
+func exchange(db *sql.DB, table, partition, archive string) error {
+	log.Printf("Exchanging %s PARTITION (%s) with %s...", table, partition, archive)
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s EXCHANGE PARTITION %s WITH TABLE %s",
+		schema.Quote(table), schema.Quote(partition), schema.Quote(archive)))
+	if err != nil {
+		return fmt.Errorf("exchange partition: %w", err)
+	}
+	return nil
+}
+
+// verifyCounts checks the partition and archive table hold exactly the
+// expected number of rows after an exchange.
+func verifyCounts(db *sql.DB, table, partition, archive string, wantPartition, wantArchive int64) error {
+	gotPartition, err := schema.CountPartition(db, table, partition)
+	if err != nil {
+		return err
+	}
+	gotArchive, err := schema.CountTable(db, archive)
+	if err != nil {
+		return err
+	}
+	if gotPartition != wantPartition || gotArchive != wantArchive {
+		return fmt.Errorf("row count mismatch: partition %s has %d (want %d), %s has %d (want %d)",
+			partition, gotPartition, wantPartition, archive, gotArchive, wantArchive)
+	}
+	log.Printf("Verified: partition %s has %d rows, %s has %d rows", partition, gotPartition, archive, gotArchive)
+	return nil
+}
+
+func requirePartition(db *sql.DB, table, partition string) error {
+	var method sql.NullString
+	err := db.QueryRow(`
+		SELECT PARTITION_METHOD FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME = ?
+	`, table, partition).Scan(&method)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s has no partition %s", table, partition)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func listPartitions(db *sql.DB, table string) error {
+	rows, err := db.Query(`
+		SELECT PARTITION_NAME, PARTITION_DESCRIPTION, TABLE_ROWS
+		FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION
+	`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-15s %-20s %15s\n", "PARTITION", "LESS THAN", "ROWS (approx)")
+	fmt.Println(strings.Repeat("-", 52))
+	for rows.Next() {
+		var name, desc string
+		var tableRows int64
+		if err := rows.Scan(&name, &desc, &tableRows); err != nil {
+			return err
+		}
+		fmt.Printf("%-15s %-20s %15d\n", name, desc, tableRows)
+	}
+	return rows.Err()
+}
+
+func rowFormat(db *sql.DB, table string) (string, error) {
+	var format string
+	err := db.QueryRow(`
+		SELECT ROW_FORMAT FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+	`, table).Scan(&format)
+	if err != nil {
+		return "", fmt.Errorf("row format of %s: %w", table, err)
+	}
+	return format, nil
+}
@@ -6,12 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
-	"os"
 	"sort"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/variant"
 )
 
 const (
@@ -33,20 +33,15 @@ type BenchmarkResult struct {
 }
 
 func main() {
-	host := flag.String("host", getEnv("DB_HOST", "localhost"), "Database host")
-	port := flag.String("port", getEnv("DB_PORT", "3306"), "Database port")
-	user := flag.String("user", getEnv("DB_USER", "app"), "Database user")
-	password := flag.String("password", getEnv("DB_PASSWORD", "app"), "Database password")
-	dbname := flag.String("db", getEnv("DB_NAME", "bookdb"), "Database name")
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
 	iterations := flag.Int("iterations", defaultIterations, "Number of iterations per query")
 	showExplain := flag.Bool("explain", false, "Show EXPLAIN output for each query")
 
 	flag.Parse()
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local",
-		*user, *password, *host, *port, *dbname)
+	cfg := dbFlags.MustLoad()
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := cfg.Open()
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -145,8 +140,8 @@ func printTableStats(db *sql.DB) {
 	fmt.Println("TABLE STATISTICS")
 	fmt.Println(strings.Repeat("=", 100))
 
-	tables := []string{"authors", "books", "tags", "book_tags", "author_tags"}
-	for _, table := range tables {
+	for _, v := range variant.Default.Bases() {
+		table := v.Table
 		var count int64
 		err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
 		if err != nil {
@@ -327,9 +322,8 @@ func showExplainOutput(db *sql.DB, query string) {
 }
 
 func showPartitionInfo(db *sql.DB) {
-	tables := []string{"books", "book_tags", "authors", "author_tags", "tags"}
-
-	for _, table := range tables {
+	for _, v := range variant.Default.Bases() {
+		table := v.Table
 		rows, err := db.Query(`
 			SELECT PARTITION_NAME, PARTITION_METHOD, PARTITION_EXPRESSION, TABLE_ROWS
 			FROM INFORMATION_SCHEMA.PARTITIONS
@@ -371,10 +365,3 @@ func truncateString(s string, maxLen int) string {
 	}
 	return s[:maxLen-3] + "..."
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
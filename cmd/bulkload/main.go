@@ -0,0 +1,441 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/internal/schema"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+const bulkSize = 5000 // Records per INSERT statement
+
+type bookRow struct {
+	id        int64
+	title     string
+	authorID  int64
+	createdAt time.Time
+}
+
+// partitionBounds describes a RANGE partition as "lower <= expr < upper".
+// lower is empty for the first partition, upper is MAXVALUE for the last.
+type partitionBounds struct {
+	expr, lower, upper string
+}
+
+// index is a secondary index as information_schema.STATISTICS describes
+// it. parts are the key parts as they appear in CREATE INDEX, with prefix
+// lengths, functional expressions and DESC.
+type index struct {
+	name  string
+	kind  string // INDEX, UNIQUE INDEX, FULLTEXT INDEX or SPATIAL INDEX
+	parts []string
+}
+
+type timing struct {
+	name     string
+	duration time.Duration
+}
+
+func main() {
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
+
+	table := flag.String("table", "books_range_year", "RANGE-partitioned books table to load into")
+	partition := flag.String("partition", "", "Empty partition to fill (e.g. p2025)")
+	numRows := flag.Int("rows", 100000, "Number of rows to generate")
+	from := flag.String("from", "", "Generate created_at from this date (YYYY-MM-DD, inclusive)")
+	to := flag.String("to", "", "Generate created_at up to this date (YYYY-MM-DD, exclusive)")
+	numAuthors := flag.Int("authors", 10000, "author_id is drawn from 1..N")
+	compare := flag.Bool("compare", true, "First time direct insertion of the same rows into the partition, then empty it again")
+	seed := flag.Int64("seed", 1, "Random seed for the generated rows")
+
+	flag.Parse()
+
+	if *partition == "" || *from == "" || *to == "" {
+		log.Fatal("-partition, -from and -to are required")
+	}
+	if v, err := variant.Default.Require(*table, variant.Range); err != nil {
+		log.Fatalf("Invalid -table: %v", err)
+	} else if v.Base != "books" {
+		log.Fatalf("Invalid -table: %s is a copy of %s, not books", v.Table, v.Base)
+	}
+	fromTime, err := time.ParseInLocation("2006-01-02", *from, time.Local)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	toTime, err := time.ParseInLocation("2006-01-02", *to, time.Local)
+	if err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+	if !toTime.After(fromTime) {
+		log.Fatal("-to must be after -from")
+	}
+
+	cfg := dbFlags.MustLoad()
+	cfg.MaxAllowedPacket = 256000000
+
+	db, err := cfg.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	log.Println("Connected to database")
+
+	bounds, err := rangeBounds(db, *table, *partition)
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+	log.Printf("%s PARTITION (%s): %s", *table, *partition, bounds)
+
+	if n, err := schema.CountPartition(db, *table, *partition); err != nil {
+		log.Fatalf("Failed: %v", err)
+	} else if n != 0 {
+		log.Fatalf("%s PARTITION (%s) is not empty (%d rows)", *table, *partition, n)
+	}
+
+	// New ids continue after the existing ones so the exchanged rows don't collide
+	var maxID sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(id) FROM " + schema.Quote(*table)).Scan(&maxID); err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+	rng := datagen.NewStream(*seed, "bulkload", 0)
+	rows := generateRows(rng, *numRows, maxID.Int64+1, *numAuthors, fromTime, toTime)
+
+	var direct []timing
+	if *compare {
+		if direct, err = directInsert(db, *table, *partition, rows); err != nil {
+			log.Fatalf("Direct insert failed: %v", err)
+		}
+	}
+
+	staged, err := stageAndExchange(db, *table, *partition, bounds, rows)
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+
+	printTimings(len(rows), staged, direct)
+}
+
+// stageAndExchange loads rows into a bare staging table, checks them
+// against the partition bounds, adds the secondary indexes and swaps the
+// staging table into the partition.
+func stageAndExchange(db *sql.DB, table, partition string, bounds partitionBounds, rows []bookRow) ([]timing, error) {
+	staging := table + "_staging"
+	var timings []timing
+	step := func(name string, fn func() error) error {
+		start := time.Now()
+		err := fn()
+		timings = append(timings, timing{name, time.Since(start)})
+		return err
+	}
+
+	indexes, err := secondaryIndexes(db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	err = step("create staging", func() error {
+		stmts := []string{
+			"DROP TABLE IF EXISTS " + schema.Quote(staging),
+			fmt.Sprintf("CREATE TABLE %s LIKE %s", schema.Quote(staging), schema.Quote(table)),
+			fmt.Sprintf("ALTER TABLE %s REMOVE PARTITIONING", schema.Quote(staging)),
+		}
+		// Load without secondary indexes; they are built once at the end
+		if len(indexes) > 0 {
+			drops := make([]string, len(indexes))
+			for i, idx := range indexes {
+				drops[i] = "DROP INDEX " + schema.Quote(idx.name)
+			}
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s", schema.Quote(staging), strings.Join(drops, ", ")))
+		}
+		return execAll(db, stmts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create staging table: %w", err)
+	}
+
+	log.Printf("Loading %d rows into %s...", len(rows), staging)
+	if err := step("load staging", func() error { return insertRows(db, staging, rows) }); err != nil {
+		return nil, fmt.Errorf("load staging table: %w", err)
+	}
+
+	err = step("validate bounds", func() error {
+		var outside int64
+		if err := db.QueryRow(bounds.outsideQuery(staging)).Scan(&outside); err != nil {
+			return err
+		}
+		if outside > 0 {
+			return fmt.Errorf("%d rows fall outside %s", outside, bounds)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("validate staging table: %w", err)
+	}
+
+	if len(indexes) > 0 {
+		log.Printf("Building %d indexes on %s...", len(indexes), staging)
+		err = step("build indexes", func() error {
+			adds := make([]string, len(indexes))
+			for i, idx := range indexes {
+				adds[i] = idx.addClause()
+			}
+			_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s %s", schema.Quote(staging), strings.Join(adds, ", ")))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("build indexes: %w", err)
+		}
+	}
+
+	// Bounds were checked above, so skip MySQL's row-by-row re-validation
+	err = step("exchange", func() error {
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s EXCHANGE PARTITION %s WITH TABLE %s WITHOUT VALIDATION",
+			schema.Quote(table), schema.Quote(partition), schema.Quote(staging)))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exchange partition: %w", err)
+	}
+
+	loaded, err := schema.CountPartition(db, table, partition)
+	if err != nil {
+		return nil, err
+	}
+	if loaded != int64(len(rows)) {
+		return nil, fmt.Errorf("row count mismatch: partition %s has %d rows, loaded %d", partition, loaded, len(rows))
+	}
+	log.Printf("Exchanged %d rows into %s PARTITION (%s)", loaded, table, partition)
+
+	if _, err := db.Exec("DROP TABLE " + schema.Quote(staging)); err != nil {
+		log.Printf("Warning: failed to drop %s: %v", staging, err)
+	}
+	return timings, nil
+}
+
+// directInsert inserts rows into the (empty) partition of the live table
+// with all indexes in place, the way an application load would, and then
+// truncates the partition again for the staged load.
+func directInsert(db *sql.DB, table, partition string, rows []bookRow) ([]timing, error) {
+	log.Printf("Loading %d rows directly into %s PARTITION (%s)...", len(rows), table, partition)
+	start := time.Now()
+	err := insertRows(db, table, rows)
+	elapsed := time.Since(start)
+
+	// Empty the partition even after a failure, so it can be loaded again
+	if _, truncErr := db.Exec(fmt.Sprintf("ALTER TABLE %s TRUNCATE PARTITION %s", schema.Quote(table), schema.Quote(partition))); truncErr != nil {
+		return nil, fmt.Errorf("truncate partition: %w", truncErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []timing{{"direct insert", elapsed}}, nil
+}
+
+func generateRows(rng *rand.Rand, count int, firstID int64, numAuthors int, from, to time.Time) []bookRow {
+	titles := []string{"The Art of", "Introduction to", "Advanced", "Complete Guide to",
+		"Mastering", "Understanding", "Practical", "Essential", "Modern", "Classic"}
+	subjects := []string{"Programming", "Design", "Science", "History", "Mathematics",
+		"Physics", "Chemistry", "Biology", "Economics", "Philosophy"}
+	span := int64(to.Sub(from) / time.Second)
+
+	rows := make([]bookRow, count)
+	for i := range rows {
+		id := firstID + int64(i)
+		rows[i] = bookRow{
+			id: id,
+			title: fmt.Sprintf("%s %s Vol.%d",
+				titles[rng.Intn(len(titles))],
+				subjects[rng.Intn(len(subjects))],
+				id),
+			authorID:  int64(rng.Intn(numAuthors) + 1),
+			createdAt: from.Add(time.Duration(rng.Int63n(span)) * time.Second),
+		}
+	}
+	return rows
+}
+
+func insertRows(db *sql.DB, table string, rows []bookRow) error {
+	for i := 0; i < len(rows); i += bulkSize {
+		end := i + bulkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		values := make([]string, 0, end-i)
+		args := make([]interface{}, 0, (end-i)*4)
+		for _, r := range rows[i:end] {
+			values = append(values, "(?, ?, ?, ?)")
+			args = append(args, r.id, r.title, r.authorID, r.createdAt)
+		}
+
+		query := "INSERT INTO " + schema.Quote(table) + " (id, title, author_id, created_at) VALUES " + strings.Join(values, ",")
+		if _, err := db.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rangeBounds(db *sql.DB, table, partition string) (partitionBounds, error) {
+	var method, expr, upper string
+	var ordinal int
+	err := db.QueryRow(`
+		SELECT PARTITION_METHOD, PARTITION_EXPRESSION, PARTITION_DESCRIPTION, PARTITION_ORDINAL_POSITION
+		FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME = ?
+	`, table, partition).Scan(&method, &expr, &upper, &ordinal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return partitionBounds{}, fmt.Errorf("%s has no partition %s", table, partition)
+	}
+	if err != nil {
+		return partitionBounds{}, err
+	}
+	if method != "RANGE" {
+		return partitionBounds{}, fmt.Errorf("%s is partitioned by %s, only RANGE is supported", table, method)
+	}
+
+	b := partitionBounds{expr: expr, upper: upper}
+	if ordinal > 1 {
+		err = db.QueryRow(`
+			SELECT PARTITION_DESCRIPTION FROM information_schema.PARTITIONS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_ORDINAL_POSITION = ?
+		`, table, ordinal-1).Scan(&b.lower)
+		if err != nil {
+			return partitionBounds{}, err
+		}
+	}
+	return b, nil
+}
+
+// outsideQuery counts the rows of table that the partition would reject.
+// The partition expression refers to columns by name, so it can be
+// evaluated against the staging table as-is.
+func (b partitionBounds) outsideQuery(table string) string {
+	var conds []string
+	if b.lower != "" {
+		conds = append(conds, fmt.Sprintf("(%s) < %s", b.expr, b.lower))
+	}
+	if b.upper != "MAXVALUE" {
+		conds = append(conds, fmt.Sprintf("(%s) >= %s", b.expr, b.upper))
+	}
+	if len(conds) == 0 {
+		return "SELECT 0"
+	}
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", schema.Quote(table), strings.Join(conds, " OR "))
+}
+
+func (b partitionBounds) String() string {
+	lower := b.lower
+	if lower == "" {
+		lower = "-inf"
+	}
+	return fmt.Sprintf("%s <= %s < %s", lower, b.expr, b.upper)
+}
+
+func secondaryIndexes(db *sql.DB, table string) ([]index, error) {
+	rows, err := db.Query(`
+		SELECT INDEX_NAME, NON_UNIQUE, INDEX_TYPE, COLUMN_NAME, SUB_PART, EXPRESSION, COLLATION
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY'
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []index
+	for rows.Next() {
+		var name, indexType string
+		var nonUnique int
+		var column, expression, collation sql.NullString
+		var subPart sql.NullInt64
+		if err := rows.Scan(&name, &nonUnique, &indexType, &column, &subPart, &expression, &collation); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].name != name {
+			indexes = append(indexes, index{name: name, kind: indexKind(nonUnique == 0, indexType)})
+		}
+		last := &indexes[len(indexes)-1]
+		last.parts = append(last.parts, keyPart(column, subPart, expression, collation))
+	}
+	return indexes, rows.Err()
+}
+
+func indexKind(unique bool, indexType string) string {
+	switch {
+	case indexType == "FULLTEXT" || indexType == "SPATIAL":
+		return indexType + " INDEX"
+	case unique:
+		return "UNIQUE INDEX"
+	}
+	return "INDEX"
+}
+
+// keyPart renders one key part: a column with an optional prefix length, or
+// a functional expression, followed by DESC for descending parts.
+func keyPart(column sql.NullString, subPart sql.NullInt64, expression, collation sql.NullString) string {
+	var part string
+	if expression.Valid {
+		part = "(" + expression.String + ")"
+	} else {
+		part = schema.Quote(column.String)
+		if subPart.Valid {
+			part += fmt.Sprintf("(%d)", subPart.Int64)
+		}
+	}
+	if collation.String == "D" {
+		part += " DESC"
+	}
+	return part
+}
+
+func (idx index) addClause() string {
+	return fmt.Sprintf("ADD %s %s (%s)", idx.kind, schema.Quote(idx.name), strings.Join(idx.parts, ", "))
+}
+
+func printTimings(count int, staged, direct []timing) {
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Printf("BULK LOAD: %d rows\n", count)
+	fmt.Println(strings.Repeat("=", 60))
+
+	var total time.Duration
+	for _, t := range staged {
+		fmt.Printf("  %-20s %15v\n", t.name, t.duration.Round(time.Millisecond))
+		total += t.duration
+	}
+	fmt.Println(strings.Repeat("-", 60))
+	fmt.Printf("  %-20s %15v  (%.0f rows/sec)\n", "staging + exchange", total.Round(time.Millisecond),
+		float64(count)/total.Seconds())
+
+	for _, t := range direct {
+		fmt.Printf("  %-20s %15v  (%.0f rows/sec)\n", t.name, t.duration.Round(time.Millisecond),
+			float64(count)/t.duration.Seconds())
+		if total > 0 {
+			fmt.Printf("  %-20s %14.2fx\n", "ratio", float64(t.duration)/float64(total))
+		}
+	}
+}
+
+func execAll(db *sql.DB, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
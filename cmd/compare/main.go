@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
 	"log"
-	"os"
 	"sort"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/internal/schema"
+	"github.com/sters/try-mysql-partitioning/shard"
+	"github.com/sters/try-mysql-partitioning/sqlscript"
+	"github.com/sters/try-mysql-partitioning/store"
+	"github.com/sters/try-mysql-partitioning/variant"
 )
 
 const (
@@ -18,41 +23,52 @@ const (
 	warmupIterations  = 3
 )
 
+var stopOnError bool
+
 type QueryPair struct {
-	Name           string
-	Description    string
-	NoPartition    string
-	WithPartition  string
-	PartitionType  string // hash, range_year, range_id, list, key
+	Name          string
+	Description   string
+	NoPartition   string
+	WithPartition string
+	PartitionType string // variant group: hash, range_year, range_id, list, key
 }
 
 type BenchResult struct {
-	Name       string
-	Durations  []time.Duration
-	RowCount   int64
-	Min        time.Duration
-	Max        time.Duration
-	Avg        time.Duration
-	Median     time.Duration
-	P95        time.Duration
+	Name      string
+	Durations []time.Duration
+	RowCount  int64
+	Min       time.Duration
+	Max       time.Duration
+	Avg       time.Duration
+	Median    time.Duration
+	P95       time.Duration
 }
 
 func main() {
-	host := flag.String("host", getEnv("DB_HOST", "localhost"), "Database host")
-	port := flag.String("port", getEnv("DB_PORT", "3306"), "Database port")
-	user := flag.String("user", getEnv("DB_USER", "app"), "Database user")
-	password := flag.String("password", getEnv("DB_PASSWORD", "app"), "Database password")
-	dbname := flag.String("db", getEnv("DB_NAME", "bookdb"), "Database name")
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
 	iterations := flag.Int("iterations", defaultIterations, "Number of iterations per query")
-	partitionType := flag.String("type", "hash", "Partition type to compare: hash, range_year, range_id, list, key, all")
+	partitionType := flag.String("type", "hash", "Partition type to compare: "+strings.Join(comparedGroups(), ", ")+", shard, all")
 	setupPartitions := flag.Bool("setup", false, "Create partition tables before comparison")
+	teardown := flag.Bool("teardown", false, "Drop the compared partition tables afterwards")
+	flag.BoolVar(&stopOnError, "stop-on-error", true, "Stop at the first failing statement of a setup script")
+	shardSchemas := flag.String("shards", "bookdb_shard0,bookdb_shard1,bookdb_shard2,bookdb_shard3", "Comma-separated shard schemas (for -type shard)")
+	shardStrategy := flag.String("shard-strategy", "hash", "Shard map for -type shard: hash or range:B1,B2,...")
 
 	flag.Parse()
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local&multiStatements=true",
-		*user, *password, *host, *port, *dbname)
+	// Get partition types to test
+	types := []string{*partitionType}
+	switch {
+	case *partitionType == "all":
+		types = comparedGroups()
+	case *partitionType == "shard":
+	case !variant.Default.HasGroup(*partitionType):
+		log.Fatalf("Unknown -type %q (known: %s, shard, all)", *partitionType, strings.Join(variant.Default.Groups(), ", "))
+	}
 
-	db, err := sql.Open("mysql", dsn)
+	cfg := dbFlags.MustLoad()
+
+	db, err := cfg.Open()
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
@@ -64,66 +80,110 @@ func main() {
 
 	log.Println("Connected to database")
 
+	if *partitionType == "shard" {
+		schemas := strings.Split(*shardSchemas, ",")
+		strategy, err := shard.ParseStrategy(*shardStrategy, len(schemas))
+		if err != nil {
+			log.Fatalf("Invalid shard strategy: %v", err)
+		}
+
+		if *setupPartitions {
+			setupPartitionTables(db, shardGroup)
+			setupShards(db, schemas, strategy)
+		}
+
+		shards := make([]config.DB, len(schemas))
+		for i, schema := range schemas {
+			shards[i] = cfg.WithName(schema)
+		}
+		router, err := shard.Open(shards, strategy)
+		if err != nil {
+			log.Fatalf("Failed to connect to shards: %v", err)
+		}
+		defer router.Close()
+
+		mc, err := cfg.MySQL()
+		if err != nil {
+			log.Fatalf("Invalid database config: %v", err)
+		}
+		runShardComparison(db, store.NewSharded(router), mc.Loc, *iterations)
+		if *teardown {
+			teardownPartitionTables(db, shardGroup)
+		}
+		return
+	}
+
 	// Setup partitions if requested
 	if *setupPartitions {
-		setupPartitionTables(db, *partitionType)
+		for _, ptype := range types {
+			setupPartitionTables(db, ptype)
+		}
 	}
 
 	// Print table stats
 	printTableStats(db)
 
-	// Get partition types to test
-	types := []string{*partitionType}
-	if *partitionType == "all" {
-		types = []string{"hash", "range_year", "range_id", "list", "key"}
-	}
-
 	for _, ptype := range types {
 		runComparison(db, ptype, *iterations)
 	}
+
+	if *teardown {
+		for _, ptype := range types {
+			teardownPartitionTables(db, ptype)
+		}
+	}
 }
 
 func setupPartitionTables(db *sql.DB, ptype string) {
 	log.Printf("Setting up partition tables for type: %s", ptype)
 
-	sqlFiles := map[string]string{
-		"hash":       "scripts/partition/hash.sql",
-		"range_year": "scripts/partition/range_by_year.sql",
-		"range_id":   "scripts/partition/range_by_id.sql",
-		"list":       "scripts/partition/list.sql",
-		"key":        "scripts/partition/key.sql",
+	for _, file := range variant.Default.Setup(ptype) {
+		executeSQLFile(db, file)
 	}
+}
 
-	if ptype == "all" {
-		for _, file := range sqlFiles {
-			executeSQLFile(db, file)
+func teardownPartitionTables(db *sql.DB, ptype string) {
+	for _, stmt := range variant.Default.Teardown(ptype) {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
 		}
-	} else if file, ok := sqlFiles[ptype]; ok {
-		executeSQLFile(db, file)
+		log.Printf("Executed: %s", stmt)
 	}
 }
 
-func executeSQLFile(db *sql.DB, filepath string) {
-	content, err := os.ReadFile(filepath)
+// executeSQLFile runs a setup script on a single connection, so session
+// state carries across statements, logging each statement's time. A failing
+// statement is fatal unless -stop-on-error=false.
+func executeSQLFile(db *sql.DB, path string) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		log.Printf("Warning: Could not read %s: %v", filepath, err)
-		return
+		log.Fatalf("Failed to get connection: %v", err)
 	}
-
-	statements := strings.Split(string(content), ";")
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
-			continue
-		}
-		if _, err := db.Exec(stmt); err != nil {
-			// Ignore some expected errors
-			if !strings.Contains(err.Error(), "doesn't exist") {
-				log.Printf("Warning executing SQL: %v", err)
+	defer conn.Close()
+
+	runner := &sqlscript.Runner{
+		Conn:        conn,
+		StopOnError: stopOnError,
+		OnResult: func(res sqlscript.Result) {
+			if res.Err != nil {
+				log.Printf("  %s: %v", res.Pos(), res.Err)
+				return
 			}
+			log.Printf("  %s %10v  %s", res.Pos(), res.Duration.Round(time.Millisecond), res.Summary(80))
+		},
+	}
+
+	start := time.Now()
+	results, err := runner.RunFile(ctx, path)
+	if err != nil {
+		if stopOnError || len(results) == 0 {
+			log.Fatalf("Failed: %v", err)
 		}
+		log.Printf("Warning: %d statements in %s failed", len(sqlscript.Failed(results)), path)
 	}
-	log.Printf("Executed: %s", filepath)
+	log.Printf("Executed: %s (%d statements in %v)", path, len(results), time.Since(start).Round(time.Millisecond))
 }
 
 func printTableStats(db *sql.DB) {
@@ -131,36 +191,40 @@ func printTableStats(db *sql.DB) {
 	fmt.Println("TABLE STATISTICS")
 	fmt.Println(strings.Repeat("=", 120))
 
-	tables := []string{
-		"books", "books_hash", "books_range_year", "books_range_id", "books_list", "books_key",
-		"book_tags", "book_tags_hash", "book_tags_range_year", "book_tags_range_id", "book_tags_key",
-	}
-
-	for _, table := range tables {
+	for _, v := range variant.Default.All() {
+		if v.Group == variant.GroupManaged {
+			continue
+		}
 		var count int64
-		err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+		err := db.QueryRow("SELECT COUNT(*) FROM " + v.Table).Scan(&count)
 		if err != nil {
 			continue // Table doesn't exist
 		}
-		fmt.Printf("%-25s: %12d rows\n", table, count)
+		fmt.Printf("%-27s: %12d rows  %s\n", v.Table, count, describe(v))
 	}
 }
 
 func runComparison(db *sql.DB, ptype string, iterations int) {
-	queries := getQueryPairs(ptype)
+	var queries []QueryPair
+	for _, qp := range queryPairs {
+		if qp.PartitionType == ptype {
+			queries = append(queries, qp)
+		}
+	}
 
 	fmt.Printf("\n%s\n", strings.Repeat("=", 120))
 	fmt.Printf("PARTITION COMPARISON: %s\n", strings.ToUpper(ptype))
 	fmt.Printf("%s\n", strings.Repeat("=", 120))
 
 	for _, qp := range queries {
-		if qp.PartitionType != ptype && qp.PartitionType != "all" {
-			continue
+		withPartition, used, err := variant.Default.Expand(ptype, qp.WithPartition)
+		if err != nil {
+			log.Fatalf("%s: %v", qp.Name, err)
 		}
 
-		// Check if partition table exists
-		if !tableExists(db, extractTableName(qp.WithPartition)) {
-			fmt.Printf("\n%s: SKIPPED (partition table not found)\n", qp.Name)
+		// Check if partition tables exist
+		if missing := missingTables(db, used); len(missing) > 0 {
+			fmt.Printf("\n%s: SKIPPED (%s not found)\n", qp.Name, strings.Join(missing, ", "))
 			continue
 		}
 
@@ -172,150 +236,200 @@ func runComparison(db *sql.DB, ptype string, iterations int) {
 		resultNoPart := benchmark(db, "No Partition", qp.NoPartition, iterations)
 
 		// Benchmark with partition
-		resultWithPart := benchmark(db, "With Partition", qp.WithPartition, iterations)
+		resultWithPart := benchmark(db, "With Partition", withPartition, iterations)
 
 		// Print comparison
 		printComparison(resultNoPart, resultWithPart)
 	}
 }
 
-func getQueryPairs(ptype string) []QueryPair {
-	pairs := []QueryPair{
-		// HASH partition queries
-		{
-			Name:          "Primary Key Lookup (single row)",
-			Description:   "SELECT by id - should benefit from HASH partition pruning",
-			NoPartition:   "SELECT id, title, author_id, created_at FROM books WHERE id = 500",
-			WithPartition: "SELECT id, title, author_id, created_at FROM books_hash WHERE id = 500",
-			PartitionType: "hash",
-		},
-		{
-			Name:          "Full Table Scan",
-			Description:   "SELECT all - partition overhead comparison",
-			NoPartition:   "SELECT COUNT(*) FROM books",
-			WithPartition: "SELECT COUNT(*) FROM books_hash",
-			PartitionType: "hash",
-		},
-		{
-			Name:          "Range Scan by ID",
-			Description:   "SELECT id range - HASH may scan all partitions",
-			NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 100 AND 500",
-			WithPartition: "SELECT id, title FROM books_hash WHERE id BETWEEN 100 AND 500",
-			PartitionType: "hash",
-		},
-		{
-			Name:          "JOIN with book_tags",
-			Description:   "JOIN operation - partition alignment matters",
-			NoPartition:   "SELECT b.id, b.title, COUNT(bt.tag_id) FROM books b LEFT JOIN book_tags bt ON b.id = bt.book_id WHERE b.id BETWEEN 1 AND 100 GROUP BY b.id, b.title",
-			WithPartition: "SELECT b.id, b.title, COUNT(bt.tag_id) FROM books_hash b LEFT JOIN book_tags_hash bt ON b.id = bt.book_id WHERE b.id BETWEEN 1 AND 100 GROUP BY b.id, b.title",
-			PartitionType: "hash",
-		},
-
-		// RANGE by year queries
-		{
-			Name:          "Date Range Query (1 year)",
-			Description:   "SELECT by date range - RANGE partition pruning",
-			NoPartition:   "SELECT id, title, created_at FROM books WHERE created_at BETWEEN '2022-01-01' AND '2022-12-31'",
-			WithPartition: "SELECT id, title, created_at FROM books_range_year WHERE created_at BETWEEN '2022-01-01' AND '2022-12-31'",
-			PartitionType: "range_year",
-		},
-		{
-			Name:          "Date Range Query (1 month)",
-			Description:   "SELECT by specific month - single partition access",
-			NoPartition:   "SELECT id, title, created_at FROM books WHERE created_at BETWEEN '2022-06-01' AND '2022-06-30'",
-			WithPartition: "SELECT id, title, created_at FROM books_range_year WHERE created_at BETWEEN '2022-06-01' AND '2022-06-30'",
-			PartitionType: "range_year",
-		},
-		{
-			Name:          "Count by Year",
-			Description:   "GROUP BY year - partition-wise aggregation",
-			NoPartition:   "SELECT YEAR(created_at) as y, COUNT(*) FROM books GROUP BY YEAR(created_at)",
-			WithPartition: "SELECT YEAR(created_at) as y, COUNT(*) FROM books_range_year GROUP BY YEAR(created_at)",
-			PartitionType: "range_year",
-		},
-		{
-			Name:          "Cross-Year Query",
-			Description:   "SELECT across multiple years - multiple partitions",
-			NoPartition:   "SELECT id, title FROM books WHERE created_at >= '2021-06-01' AND created_at < '2023-06-01' LIMIT 1000",
-			WithPartition: "SELECT id, title FROM books_range_year WHERE created_at >= '2021-06-01' AND created_at < '2023-06-01' LIMIT 1000",
-			PartitionType: "range_year",
-		},
-
-		// RANGE by ID queries
-		{
-			Name:          "ID Range (within partition)",
-			Description:   "SELECT id range within single partition boundary",
-			NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 50000 AND 99999",
-			WithPartition: "SELECT id, title FROM books_range_id WHERE id BETWEEN 50000 AND 99999",
-			PartitionType: "range_id",
-		},
-		{
-			Name:          "ID Range (cross partition)",
-			Description:   "SELECT id range across partition boundaries",
-			NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 95000 AND 105000",
-			WithPartition: "SELECT id, title FROM books_range_id WHERE id BETWEEN 95000 AND 105000",
-			PartitionType: "range_id",
-		},
+// queryPairs compare a base table query with the same query on a group's
+// variants. {books}, {book_tags}, ... in WithPartition are replaced with the
+// group's table for that base (see variant.Registry.Expand).
+var queryPairs = []QueryPair{
+	// HASH partition queries
+	{
+		Name:          "Primary Key Lookup (single row)",
+		Description:   "SELECT by id - should benefit from HASH partition pruning",
+		NoPartition:   "SELECT id, title, author_id, created_at FROM books WHERE id = 500",
+		WithPartition: "SELECT id, title, author_id, created_at FROM {books} WHERE id = 500",
+		PartitionType: "hash",
+	},
+	{
+		Name:          "Full Table Scan",
+		Description:   "SELECT all - partition overhead comparison",
+		NoPartition:   "SELECT COUNT(*) FROM books",
+		WithPartition: "SELECT COUNT(*) FROM {books}",
+		PartitionType: "hash",
+	},
+	{
+		Name:          "Range Scan by ID",
+		Description:   "SELECT id range - HASH may scan all partitions",
+		NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 100 AND 500",
+		WithPartition: "SELECT id, title FROM {books} WHERE id BETWEEN 100 AND 500",
+		PartitionType: "hash",
+	},
+	{
+		Name:          "JOIN with book_tags",
+		Description:   "JOIN operation - partition alignment matters",
+		NoPartition:   "SELECT b.id, b.title, COUNT(bt.tag_id) FROM books b LEFT JOIN book_tags bt ON b.id = bt.book_id WHERE b.id BETWEEN 1 AND 100 GROUP BY b.id, b.title",
+		WithPartition: "SELECT b.id, b.title, COUNT(bt.tag_id) FROM {books} b LEFT JOIN {book_tags} bt ON b.id = bt.book_id WHERE b.id BETWEEN 1 AND 100 GROUP BY b.id, b.title",
+		PartitionType: "hash",
+	},
+
+	// RANGE by year queries
+	{
+		Name:          "Date Range Query (1 year)",
+		Description:   "SELECT by date range - RANGE partition pruning",
+		NoPartition:   "SELECT id, title, created_at FROM books WHERE created_at BETWEEN '2022-01-01' AND '2022-12-31'",
+		WithPartition: "SELECT id, title, created_at FROM {books} WHERE created_at BETWEEN '2022-01-01' AND '2022-12-31'",
+		PartitionType: "range_year",
+	},
+	{
+		Name:          "Date Range Query (1 month)",
+		Description:   "SELECT by specific month - single partition access",
+		NoPartition:   "SELECT id, title, created_at FROM books WHERE created_at BETWEEN '2022-06-01' AND '2022-06-30'",
+		WithPartition: "SELECT id, title, created_at FROM {books} WHERE created_at BETWEEN '2022-06-01' AND '2022-06-30'",
+		PartitionType: "range_year",
+	},
+	{
+		Name:          "Count by Year",
+		Description:   "GROUP BY year - partition-wise aggregation",
+		NoPartition:   "SELECT YEAR(created_at) as y, COUNT(*) FROM books GROUP BY YEAR(created_at)",
+		WithPartition: "SELECT YEAR(created_at) as y, COUNT(*) FROM {books} GROUP BY YEAR(created_at)",
+		PartitionType: "range_year",
+	},
+	{
+		Name:          "Cross-Year Query",
+		Description:   "SELECT across multiple years - multiple partitions",
+		NoPartition:   "SELECT id, title FROM books WHERE created_at >= '2021-06-01' AND created_at < '2023-06-01' LIMIT 1000",
+		WithPartition: "SELECT id, title FROM {books} WHERE created_at >= '2021-06-01' AND created_at < '2023-06-01' LIMIT 1000",
+		PartitionType: "range_year",
+	},
+
+	// RANGE by ID queries
+	{
+		Name:          "ID Range (within partition)",
+		Description:   "SELECT id range within single partition boundary",
+		NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 50000 AND 99999",
+		WithPartition: "SELECT id, title FROM {books} WHERE id BETWEEN 50000 AND 99999",
+		PartitionType: "range_id",
+	},
+	{
+		Name:          "ID Range (cross partition)",
+		Description:   "SELECT id range across partition boundaries",
+		NoPartition:   "SELECT id, title FROM books WHERE id BETWEEN 95000 AND 105000",
+		WithPartition: "SELECT id, title FROM {books} WHERE id BETWEEN 95000 AND 105000",
+		PartitionType: "range_id",
+	},
+
+	// LIST partition queries
+	{
+		Name:          "Status Filter (single value)",
+		Description:   "SELECT by status - single partition access",
+		NoPartition:   "SELECT COUNT(*) FROM books",
+		WithPartition: "SELECT COUNT(*) FROM {books} WHERE status = 1",
+		PartitionType: "list",
+	},
+	{
+		Name:          "Status Filter (multiple values)",
+		Description:   "SELECT by multiple statuses",
+		NoPartition:   "SELECT COUNT(*) FROM books",
+		WithPartition: "SELECT COUNT(*) FROM {books} WHERE status IN (0, 1)",
+		PartitionType: "list",
+	},
+
+	// KEY partition queries
+	{
+		Name:          "Composite Key Lookup",
+		Description:   "SELECT by composite key - KEY partition optimization",
+		NoPartition:   "SELECT * FROM book_tags WHERE book_id = 100 AND tag_id = 5",
+		WithPartition: "SELECT * FROM {book_tags} WHERE book_id = 100 AND tag_id = 5",
+		PartitionType: "key",
+	},
+	{
+		Name:          "Partial Key Lookup",
+		Description:   "SELECT by partial key - may scan all partitions",
+		NoPartition:   "SELECT * FROM book_tags WHERE book_id = 100",
+		WithPartition: "SELECT * FROM {book_tags} WHERE book_id = 100",
+		PartitionType: "key",
+	},
+
+	// Batched relation loading, as done by the API for ?expand=
+	{
+		Name:          "Expand tags (batched IN)",
+		Description:   "Tags of a page of books in one IN-query - prunes to the partitions of the listed ids",
+		NoPartition:   "SELECT bt.book_id, t.id, t.name FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id IN (" + expandIDs + ")",
+		WithPartition: "SELECT bt.book_id, t.id, t.name FROM {book_tags} bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id IN (" + expandIDs + ")",
+		PartitionType: "hash",
+	},
+	{
+		Name:          "Expand tags (batched IN)",
+		Description:   "Tags of a page of books in one IN-query - KEY on (book_id, tag_id) cannot prune by book_id alone",
+		NoPartition:   "SELECT bt.book_id, t.id, t.name FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id IN (" + expandIDs + ")",
+		WithPartition: "SELECT bt.book_id, t.id, t.name FROM {book_tags} bt JOIN tags t ON t.id = bt.tag_id WHERE bt.book_id IN (" + expandIDs + ")",
+		PartitionType: "key",
+	},
+}
 
-		// LIST partition queries
-		{
-			Name:          "Status Filter (single value)",
-			Description:   "SELECT by status - single partition access",
-			NoPartition:   "SELECT COUNT(*) FROM books",
-			WithPartition: "SELECT COUNT(*) FROM books_list WHERE status = 1",
-			PartitionType: "list",
-		},
-		{
-			Name:          "Status Filter (multiple values)",
-			Description:   "SELECT by multiple statuses",
-			NoPartition:   "SELECT COUNT(*) FROM books",
-			WithPartition: "SELECT COUNT(*) FROM books_list WHERE status IN (0, 1)",
-			PartitionType: "list",
-		},
+// expandIDs is a page of 20 book ids spread over the id space.
+const expandIDs = "1, 5003, 10007, 15013, 20011, 25013, 30011, 35023, 40009, 45007, " +
+	"50021, 55001, 60013, 65003, 70001, 75011, 80021, 85009, 90001, 95003"
 
-		// KEY partition queries
-		{
-			Name:          "Composite Key Lookup",
-			Description:   "SELECT by composite key - KEY partition optimization",
-			NoPartition:   "SELECT * FROM book_tags WHERE book_id = 100 AND tag_id = 5",
-			WithPartition: "SELECT * FROM book_tags_key WHERE book_id = 100 AND tag_id = 5",
-			PartitionType: "key",
-		},
-		{
-			Name:          "Partial Key Lookup",
-			Description:   "SELECT by partial key - may scan all partitions",
-			NoPartition:   "SELECT * FROM book_tags WHERE book_id = 100",
-			WithPartition: "SELECT * FROM book_tags_key WHERE book_id = 100",
-			PartitionType: "key",
-		},
+func missingTables(db *sql.DB, variants []variant.Variant) []string {
+	var missing []string
+	for _, v := range variants {
+		exists, err := schema.TableExists(db, v.Table)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !exists {
+			missing = append(missing, v.Table)
+		}
 	}
-
-	return pairs
+	return missing
 }
 
-func tableExists(db *sql.DB, tableName string) bool {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", tableName).Scan(&count)
-	return err == nil && count > 0
+// comparedGroups returns the variant groups that have query pairs, in
+// registry order.
+func comparedGroups() []string {
+	var groups []string
+	for _, g := range variant.Default.Groups() {
+		for _, qp := range queryPairs {
+			if qp.PartitionType == g {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+	return groups
 }
 
-func extractTableName(query string) string {
-	query = strings.ToLower(query)
-	// Find FROM clause
-	fromIdx := strings.Index(query, " from ")
-	if fromIdx == -1 {
+func describe(v variant.Variant) string {
+	if v.Method == variant.None {
 		return ""
 	}
-	rest := strings.TrimSpace(query[fromIdx+6:])
-	// Get table name (first word)
-	parts := strings.Fields(rest)
-	if len(parts) == 0 {
-		return ""
-	}
-	return parts[0]
+	return fmt.Sprintf("%s(%s) x %d", v.Method, v.Key, v.Partitions)
 }
 
 func benchmark(db *sql.DB, name, query string, iterations int) BenchResult {
+	return benchmarkFunc(name, iterations, func() (int64, error) {
+		rows, err := db.Query(query)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+
+		count := int64(0)
+		for rows.Next() {
+			count++
+		}
+		return count, rows.Err()
+	})
+}
+
+// benchmarkFunc times run, which returns the number of rows it read.
+func benchmarkFunc(name string, iterations int, run func() (int64, error)) BenchResult {
 	result := BenchResult{
 		Name:      name,
 		Durations: make([]time.Duration, 0, iterations),
@@ -324,29 +438,18 @@ func benchmark(db *sql.DB, name, query string, iterations int) BenchResult {
 
 	// Warmup
 	for i := 0; i < warmupIterations; i++ {
-		rows, err := db.Query(query)
-		if err == nil {
-			for rows.Next() {
-			}
-			rows.Close()
-		}
+		run()
 	}
 
 	// Benchmark
 	for i := 0; i < iterations; i++ {
 		start := time.Now()
-		rows, err := db.Query(query)
+		count, err := run()
 		if err != nil {
 			log.Printf("Query error: %v", err)
 			continue
 		}
 
-		count := int64(0)
-		for rows.Next() {
-			count++
-		}
-		rows.Close()
-
 		duration := time.Since(start)
 		result.Durations = append(result.Durations, duration)
 		result.RowCount = count
@@ -419,10 +522,3 @@ func printComparison(noPart, withPart BenchResult) {
 		fmt.Printf("  %-20s | %s\n", "Comparison", indicator)
 	}
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/internal/schema"
+	"github.com/sters/try-mysql-partitioning/shard"
+	"github.com/sters/try-mysql-partitioning/store"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+// shardGroup is the variant group whose partitioning matches the shard key,
+// used as the native side of the comparison.
+const shardGroup = "hash_author"
+
+// setupShards copies books into each shard schema according to strategy.
+// The schemas are created by mysql/init/002_shards.sql. A failure is fatal:
+// comparing against a missing or partial shard gives wrong numbers.
+func setupShards(db *sql.DB, schemas []string, strategy shard.Strategy) {
+	for i, schema := range schemas {
+		log.Printf("Populating shard %d (%s)...", i, schema)
+
+		stmts := []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS `%s`.books", schema),
+			fmt.Sprintf("CREATE TABLE `%s`.books LIKE books", schema),
+			fmt.Sprintf("INSERT INTO `%s`.books (id, title, author_id, created_at) SELECT id, title, author_id, created_at FROM books WHERE %s",
+				schema, strategy.Predicate(i, "author_id")),
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				log.Fatalf("Failed to populate shard %s: %v", schema, err)
+			}
+		}
+	}
+}
+
+func runShardComparison(db *sql.DB, books *store.Sharded, zone *time.Location, iterations int) {
+	ctx := context.Background()
+	from := time.Date(2022, 6, 1, 0, 0, 0, 0, zone)
+
+	v, err := variant.Default.For(shardGroup, "books")
+	if err != nil {
+		log.Fatal(err)
+	}
+	native := v.Table
+
+	fmt.Printf("\n%s\n", strings.Repeat("=", 120))
+	fmt.Printf("SHARDING COMPARISON: %d shards vs %s\n", len(books.Router.Shards), native)
+	fmt.Printf("%s\n", strings.Repeat("=", 120))
+
+	if exists, err := schema.TableExists(db, native); err != nil {
+		log.Fatal(err)
+	} else if !exists {
+		fmt.Printf("\nSKIPPED (%s not found, run with -setup)\n", native)
+		return
+	}
+
+	// The shards must hold exactly the rows of books, or the two sides
+	// compare different data
+	total, err := schema.CountTable(db, "books")
+	if err != nil {
+		log.Fatalf("Failed to count books: %v", err)
+	}
+	sharded, err := books.CountBooks(ctx)
+	if err != nil {
+		log.Fatalf("Failed to count the shards (run with -setup): %v", err)
+	}
+	if sharded != total {
+		log.Fatalf("The shards hold %d books, books has %d: run with -setup to repopulate them", sharded, total)
+	}
+
+	cases := []struct {
+		name        string
+		description string
+		native      string
+		sharded     func() (int64, error)
+	}{
+		{
+			name:        "Author Lookup (shard key)",
+			description: "SELECT by author_id - single partition vs single shard",
+			native:      "SELECT id, title, author_id, created_at FROM " + native + " WHERE author_id = 500",
+			sharded: func() (int64, error) {
+				bs, err := books.ListBooksByAuthor(ctx, 500)
+				return int64(len(bs)), err
+			},
+		},
+		{
+			name:        "Primary Key Lookup (no shard key)",
+			description: "SELECT by id - all partitions vs scatter-gather over all shards",
+			native:      "SELECT id, title, author_id, created_at FROM " + native + " WHERE id = 500",
+			sharded: func() (int64, error) {
+				if _, err := books.GetBook(ctx, 500); err != nil && !errors.Is(err, store.ErrNotFound) {
+					return 0, err
+				}
+				return 1, nil
+			},
+		},
+		{
+			name:        "Date Range Query (1 month)",
+			description: "SELECT by created_at - scatter-gather",
+			native:      "SELECT id, title, author_id, created_at FROM " + native + " WHERE created_at >= '2022-06-01' AND created_at < '2022-07-01'",
+			sharded: func() (int64, error) {
+				bs, err := books.ListBooksCreatedBetween(ctx, from, from.AddDate(0, 1, 0))
+				return int64(len(bs)), err
+			},
+		},
+		{
+			name:        "Full Table Count",
+			description: "COUNT(*) - partition-wise vs parallel per-shard count",
+			native:      "SELECT COUNT(*) FROM " + native,
+			sharded: func() (int64, error) {
+				_, err := books.CountBooks(ctx)
+				return 1, err
+			},
+		},
+	}
+
+	for _, c := range cases {
+		fmt.Printf("\n%s\n", c.name)
+		fmt.Printf("  %s\n", c.description)
+		fmt.Println(strings.Repeat("-", 100))
+
+		resultNative := benchmark(db, "Native Partition", c.native, iterations)
+		resultSharded := benchmarkFunc("Sharded", iterations, c.sharded)
+
+		printComparison(resultNative, resultSharded)
+	}
+}
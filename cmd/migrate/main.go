@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/migrate"
+	"github.com/sters/try-mysql-partitioning/migrations"
+)
+
+func main() {
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
+	lockTimeout := flag.Duration("lock-timeout", migrate.LockTimeout, "How long to wait for another migration to finish")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] status | up | down [N] | to VERSION\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg := dbFlags.MustLoad()
+	migrate.LockTimeout = *lockTimeout
+
+	db, err := cfg.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	m := migrate.New(db, all)
+	m.Log = log.Printf
+
+	ctx := context.Background()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "status":
+		err = printStatus(ctx, m)
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("Invalid step count: %s", args[1])
+			}
+		}
+		err = m.Down(ctx, n)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal("to requires a VERSION")
+		}
+		version, perr := strconv.ParseInt(args[1], 10, 64)
+		if perr != nil || version < 0 {
+			log.Fatalf("Invalid version: %s", args[1])
+		}
+		err = m.To(ctx, version)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("Failed: %v", err)
+	}
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-8s %-30s %-10s %s\n", "VERSION", "NAME", "STATE", "APPLIED AT")
+	for _, s := range statuses {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "MISSING"
+		case s.Modified:
+			state = "MODIFIED"
+		case s.Applied:
+			state = "applied"
+		}
+		appliedAt := ""
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Printf("%-8d %-30s %-10s %s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return nil
+}
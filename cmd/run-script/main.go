@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/sqlscript"
+)
+
+func main() {
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
+
+	stopOnError := flag.Bool("stop-on-error", true, "Stop at the first failing statement")
+	printRows := flag.Bool("print", true, "Print the result sets of SELECT, SHOW and EXPLAIN")
+	maxRows := flag.Int("max-rows", 50, "Rows to print per result set (0 = all)")
+	dryRun := flag.Bool("dry-run", false, "Only parse the scripts and list their statements")
+	slowest := flag.Int("slowest", 5, "Number of slowest statements to list at the end")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] FILE...\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Parse everything first so a syntax problem in a later file is found
+	// before anything runs
+	var stmts []sqlscript.Statement
+	for _, file := range files {
+		s, err := sqlscript.ParseFile(file)
+		if err != nil {
+			log.Fatalf("Failed to parse: %v", err)
+		}
+		stmts = append(stmts, s...)
+	}
+
+	if *dryRun {
+		for _, s := range stmts {
+			fmt.Printf("%s  %s\n", s.Pos(), s.Summary(100))
+		}
+		return
+	}
+
+	cfg := dbFlags.MustLoad()
+
+	db, err := cfg.Open()
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// One session for the whole run: scripts rely on user variables,
+	// SET profiling and procedures created earlier in the same file
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	runner := &sqlscript.Runner{
+		Conn:        conn,
+		StopOnError: *stopOnError,
+		OnResult:    printResult,
+	}
+	if *printRows {
+		runner.OnRows = func(_ sqlscript.Statement, rows *sql.Rows) error {
+			return printTable(rows, *maxRows)
+		}
+	}
+
+	start := time.Now()
+	results, runErr := runner.Run(ctx, stmts)
+	elapsed := time.Since(start)
+
+	printSummary(results, len(stmts), elapsed, *slowest)
+	if runErr != nil {
+		os.Exit(1)
+	}
+}
+
+func printResult(res sqlscript.Result) {
+	status := "ok"
+	if res.Err != nil {
+		status = "ERROR: " + res.Err.Error()
+	} else if res.RowsAffected > 0 {
+		status = fmt.Sprintf("%d rows affected", res.RowsAffected)
+	}
+	fmt.Printf("%s %10v  %s  -- %s\n", res.Pos(), res.Duration.Round(time.Microsecond), res.Summary(80), status)
+}
+
+func printTable(rows *sql.Rows, maxRows int) error {
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(cols, "\t"))
+
+	values := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		n++
+		if maxRows > 0 && n > maxRows {
+			continue
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				cells[i] = "NULL"
+			} else {
+				cells[i] = strings.ReplaceAll(string(v), "\n", " ")
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+	if maxRows > 0 && n > maxRows {
+		fmt.Printf("... %d more rows\n", n-maxRows)
+	}
+	fmt.Printf("(%d rows)\n\n", n)
+	return rows.Err()
+}
+
+func printSummary(results []sqlscript.Result, total int, elapsed time.Duration, slowest int) {
+	failed := sqlscript.Failed(results)
+
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Printf("%d of %d statements run, %d failed, %v total\n", len(results), total, len(failed), elapsed.Round(time.Millisecond))
+	for _, res := range failed {
+		fmt.Printf("  FAILED %s: %v\n", res.Pos(), res.Err)
+	}
+
+	if slowest <= 0 || len(results) == 0 {
+		return
+	}
+	sorted := append([]sqlscript.Result(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Duration > sorted[j].Duration })
+	if len(sorted) > slowest {
+		sorted = sorted[:slowest]
+	}
+
+	fmt.Println("\nSlowest statements:")
+	for _, res := range sorted {
+		fmt.Printf("  %10v  %s  %s\n", res.Duration.Round(time.Microsecond), res.Pos(), res.Summary(60))
+	}
+}
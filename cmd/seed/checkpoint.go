@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Each batch is committed together with its worker's checkpoint, so after a
+// crash the checkpoint matches the rows in the table exactly. A resumed
+// worker replays its stream and skips the rows it already inserted, which
+// gives the same rows as an uninterrupted run.
+const createCheckpoints = `
+	CREATE TABLE IF NOT EXISTS seed_checkpoints (
+		table_name VARCHAR(64) NOT NULL,
+		worker INT NOT NULL,
+		fingerprint CHAR(64) NOT NULL,
+		rows_done BIGINT NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT FALSE,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (table_name, worker)
+	) ENGINE=InnoDB`
+
+// checkpoint is the committed progress of one worker.
+type checkpoint struct {
+	rows int64
+	done bool
+}
+
+func ensureCheckpoints(db *sql.DB) error {
+	_, err := db.Exec(createCheckpoints)
+	return err
+}
+
+// loadCheckpoints returns the checkpoints of table by worker.
+func loadCheckpoints(db *sql.DB, table string) (map[int]checkpoint, error) {
+	rows, err := db.Query("SELECT worker, fingerprint, rows_done, done FROM seed_checkpoints WHERE table_name = ?", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cps := make(map[int]checkpoint)
+	for rows.Next() {
+		var worker int
+		var fp string
+		var cp checkpoint
+		if err := rows.Scan(&worker, &fp, &cp.rows, &cp.done); err != nil {
+			return nil, err
+		}
+		if fp != runFingerprint {
+			return nil, fmt.Errorf("%s was seeded with a different scenario; resume with -scenario set to its manifest, or start over with -truncate", table)
+		}
+		cps[worker] = cp
+	}
+	return cps, rows.Err()
+}
+
+// loadAllCheckpoints returns the checkpoints of the tables to resume. A
+// table with rows but no checkpoints was not filled by an interrupted run
+// and cannot be resumed.
+func loadAllCheckpoints(db *sql.DB, ts []table) map[string]map[int]checkpoint {
+	all := make(map[string]map[int]checkpoint)
+	for _, t := range ts {
+		if t.count == 0 {
+			continue
+		}
+		cps, err := loadCheckpoints(db, t.name)
+		if err != nil {
+			log.Fatalf("Cannot resume: %v", err)
+		}
+		if len(cps) == 0 && len(nonEmpty(db, []table{t})) > 0 {
+			log.Fatalf("Cannot resume: %s has rows but no checkpoints; start over with -truncate", t.name)
+		}
+		all[t.name] = cps
+	}
+	return all
+}
+
+func clearCheckpoints(db *sql.DB, table string) error {
+	_, err := db.Exec("DELETE FROM seed_checkpoints WHERE table_name = ?", table)
+	return err
+}
+
+// execer is *sql.DB or *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func saveCheckpoint(e execer, table string, worker int, cp checkpoint) error {
+	_, err := e.Exec(`
+		INSERT INTO seed_checkpoints (table_name, worker, fingerprint, rows_done, done)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE fingerprint = VALUES(fingerprint), rows_done = VALUES(rows_done), done = VALUES(done)`,
+		table, worker, runFingerprint, cp.rows, cp.done)
+	return err
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// exportManifest is the manifest -export writes next to the files
+	exportManifest = "manifest.json"
+)
+
+// exportPath is the file of a base table in dir. Files hold the columns of
+// the base table: CSV files with a header line, NDJSON files with one object
+// per row. DATETIMEs are wall-clock values in the -tz zone, as stored.
+func exportPath(dir, table, format string) string {
+	return filepath.Join(dir, table+"."+format+".gz")
+}
+
+// exportTables returns the base tables of targets.
+func exportTables(targets []variant.Variant, bases []table) []table {
+	var tables []table
+	seen := make(map[string]bool)
+	for _, v := range targets {
+		if !seen[v.Base] {
+			seen[v.Base] = true
+			tables = append(tables, bases[indexOf(generated, v.Base)])
+		}
+	}
+	return tables
+}
+
+// runExport writes tables to dir, followed by a manifest that -import and
+// -scenario read.
+func runExport(dir, format string, sc Scenario, tables []table) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	startTime = time.Now()
+	manifest := newManifest(sc, startTime)
+
+	written := make(map[string]int64)
+	rates := make(map[string]float64)
+	var exported []table
+	for _, t := range tables {
+		if t.count == 0 {
+			continue
+		}
+		t.mustPrepare(nil)
+		path := exportPath(dir, t.name, format)
+		log.Printf("Exporting %d %s to %s...", t.count, t.name, path)
+
+		start := time.Now()
+		n, err := exportTable(path, format, t)
+		if err != nil {
+			log.Fatalf("Failed to export %s: %v", t.name, err)
+		}
+		elapsed := time.Since(start)
+		written[t.name], rates[t.name] = n, float64(n)/elapsed.Seconds()
+		log.Printf("Exported %s: %d rows in %v, %.0f rows/sec", t.name, n, elapsed.Round(time.Millisecond), rates[t.name])
+		exported = append(exported, t)
+	}
+
+	manifest.Duration = time.Since(startTime).Round(time.Millisecond).String()
+	for _, t := range exported {
+		manifest.Tables = append(manifest.Tables, TableManifest{
+			Table: t.name, Requested: t.count, Inserted: written[t.name], Rows: written[t.name], RowsPerSec: math.Round(rates[t.name]),
+		})
+	}
+	path := filepath.Join(dir, exportManifest)
+	if err := manifest.write(path); err != nil {
+		log.Fatalf("Failed to write manifest: %v", err)
+	}
+	log.Printf("Manifest written to %s", path)
+}
+
+// exportTable writes the rows of t in the order of its workers, which for
+// every table is the order of the first column.
+func exportTable(path, format string, t table) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	bw := bufio.NewWriterSize(zw, 1<<20)
+
+	enc, err := newEncoder(format, bw, t.columns)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for w := 0; w < t.workers && err == nil; w++ {
+		start, end := t.workerRange(w)
+		generate(t, w, start, end, 0, func(rows [][]interface{}) {
+			for _, row := range rows {
+				if err != nil {
+					return
+				}
+				err = enc.Write(row)
+				n++
+			}
+		})
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		return n, err
+	}
+	if err := bw.Flush(); err != nil {
+		return n, err
+	}
+	if err := zw.Close(); err != nil {
+		return n, err
+	}
+	return n, f.Close()
+}
+
+// rowEncoder writes rows in one of the file formats.
+type rowEncoder interface {
+	Write(row []interface{}) error
+	Flush() error
+}
+
+func newEncoder(format string, w io.Writer, columns []string) (rowEncoder, error) {
+	switch format {
+	case formatCSV:
+		e := &csvEncoder{w: csv.NewWriter(w), record: make([]string, len(columns))}
+		return e, e.w.Write(columns)
+	case formatNDJSON:
+		e := &ndjsonEncoder{w: w, keys: make([][]byte, len(columns))}
+		for i, c := range columns {
+			e.keys[i], _ = json.Marshal(c)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (e *csvEncoder) Write(row []interface{}) error {
+	for i, v := range row {
+		e.record[i] = formatValue(v)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	w    io.Writer
+	keys [][]byte
+	line []byte
+}
+
+// Write writes ids as numbers and the rest as strings.
+func (e *ndjsonEncoder) Write(row []interface{}) error {
+	line := append(e.line[:0], '{')
+	for i, v := range row {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(append(line, e.keys[i]...), ':')
+		switch v := v.(type) {
+		case int:
+			line = strconv.AppendInt(line, int64(v), 10)
+		case int64:
+			line = strconv.AppendInt(line, v, 10)
+		default:
+			s, _ := json.Marshal(formatValue(v))
+			line = append(line, s...)
+		}
+	}
+	e.line = append(line, '}', '\n')
+	_, err := e.w.Write(e.line)
+	return err
+}
+
+func (e *ndjsonEncoder) Flush() error { return nil }
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case time.Time:
+		return v.Format(datetimeLayout)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// findExport returns the file of a base table in dir and its format.
+func findExport(dir, table string) (string, string, error) {
+	for _, format := range []string{formatCSV, formatNDJSON} {
+		if path := exportPath(dir, table, format); fileExists(path) {
+			return path, format, nil
+		}
+	}
+	return "", "", fmt.Errorf("no %s.csv.gz or %s.ndjson.gz in %s", table, table, dir)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// newDecoder returns a function that reads the next row of r as values in
+// the order of columns, or io.EOF.
+func newDecoder(format string, r io.Reader, columns []string) (func() ([]interface{}, error), error) {
+	switch format {
+	case formatCSV:
+		cr := csv.NewReader(r)
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("header: %w", err)
+		}
+		order := make([]int, len(columns))
+		for i, c := range columns {
+			if order[i] = indexOf(header, c); order[i] < 0 {
+				return nil, fmt.Errorf("no column %s in the header", c)
+			}
+		}
+		return func() ([]interface{}, error) {
+			record, err := cr.Read()
+			if err != nil {
+				return nil, err
+			}
+			row := make([]interface{}, len(columns))
+			for i, j := range order {
+				row[i] = record[j]
+			}
+			return row, nil
+		}, nil
+
+	case formatNDJSON:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		return func() ([]interface{}, error) {
+			var obj map[string]interface{}
+			if err := dec.Decode(&obj); err != nil {
+				return nil, err
+			}
+			row := make([]interface{}, len(columns))
+			for i, c := range columns {
+				v, ok := obj[c]
+				if !ok {
+					return nil, fmt.Errorf("no column %s in %v", c, obj)
+				}
+				row[i] = fmt.Sprint(v)
+			}
+			return row, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// importTable loads the file of t's base from dir into t with the -method,
+// t.workers batches at a time. It returns the rows inserted and the rows per
+// second; inserting other than the scenario's row count is fatal. The values
+// of extra columns are drawn from the stream of the worker that would have
+// generated the row, so an imported variant has the same rows as a seeded
+// one.
+func importTable(conn *sql.DB, dir string, t table) (int64, float64) {
+	if t.count == 0 {
+		return 0, 0
+	}
+	path, format, err := findExport(dir, t.base)
+	if err != nil {
+		log.Fatalf("Cannot import %s: %v", t.name, err)
+	}
+	t.mustPrepare(conn)
+	log.Printf("Importing %s into %s with %d workers...", path, t.name, t.workers)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Cannot import %s: %v", t.name, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(bufio.NewReaderSize(f, 1<<20))
+	if err != nil {
+		log.Fatalf("Cannot import %s: %v", path, err)
+	}
+	start := time.Now()
+	var inserted int64
+	batches := make(chan [][]interface{}, t.workers)
+	var wg sync.WaitGroup
+	for w := 0; w < t.workers; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			write := newBatchWriter(method, t, workerID)
+			for rows := range batches {
+				n, err := execBatch(conn, "import "+t.name, func(tx *sql.Tx) (sql.Result, error) {
+					return write(tx, rows)
+				})
+				if err != nil {
+					log.Fatalf("Worker %d: Error importing %s: %v", workerID, t.name, err)
+				}
+				atomic.AddInt64(&inserted, n)
+				atomic.AddInt64(&totalInserted, n)
+			}
+		}(w)
+	}
+
+	err = readRows(zr, format, t, func(rows [][]interface{}) { batches <- rows })
+	close(batches)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	rate := float64(inserted) / elapsed.Seconds()
+	log.Printf("Imported %s: %d rows in %v, %.0f rows/sec (%s)", t.name, inserted, elapsed.Round(time.Millisecond), rate, method)
+	if inserted != int64(t.count) {
+		log.Fatalf("Importing %s: %d rows imported, %d in the scenario", t.name, inserted, t.count)
+	}
+	return inserted, rate
+}
+
+// readRows passes the rows of a file of t's base to batch, t.batchSize rows
+// at a time, with t's extra columns added. Each batch is a new slice.
+func readRows(r io.Reader, format string, t table, batch func(rows [][]interface{})) error {
+	next, err := newDecoder(format, r, t.columns[:len(t.columns)-len(t.extra)])
+	if err != nil {
+		return err
+	}
+
+	extraRngs := make([]*rand.Rand, t.workers)
+	rows := make([][]interface{}, 0, t.batchSize)
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(t.extra) > 0 {
+			// The first column is the id, or the owner id of a link
+			id, err := strconv.Atoi(row[0].(string))
+			if err != nil {
+				return fmt.Errorf("%s is not an id", row[0])
+			}
+			w := t.worker(id - 1)
+			if extraRngs[w] == nil {
+				extraRngs[w] = datagen.NewStream(seed, t.name+"/extra", w)
+			}
+			row = append(row, t.extraRow(extraRngs[w])...)
+		}
+		rows = append(rows, row)
+		if len(rows) == t.batchSize {
+			batch(rows)
+			rows = make([][]interface{}, 0, t.batchSize)
+		}
+	}
+	if len(rows) > 0 {
+		batch(rows)
+	}
+	return nil
+}
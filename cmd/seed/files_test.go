@@ -0,0 +1,121 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTableWorker(t *testing.T) {
+	for _, c := range []struct{ units, workers int }{{10, 3}, {9, 3}, {2, 5}, {0, 2}} {
+		tbl := table{units: c.units, workers: c.workers}
+		for w := 0; w < c.workers; w++ {
+			start, end := tbl.workerRange(w)
+			for u := start; u < end; u++ {
+				if got := tbl.worker(u); got != w {
+					t.Errorf("%d units, %d workers: worker(%d) = %d, want %d", c.units, c.workers, u, got, w)
+				}
+			}
+		}
+	}
+}
+
+// TestExportImport checks that a variant imported from an export gets the
+// rows, extra columns included, that seeding it directly generates.
+func TestExportImport(t *testing.T) {
+	seed = 7
+	sc := defaultScenario()
+	sc.Seed = seed
+	sc.Tables["authors"].Count = 50
+	sc.Tables["tags"].Count = 20
+	sc.Tables["author_tags"].Count = 300
+	sc.Tables["author_tags"].Workers = 3
+	sc.Tables["author_tags"].BatchSize = 64
+	if err := sc.resolve(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := sc.dataset(time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := resolveTargets("author_tags_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bases := baseTables(sc, ds)
+	tables, err := targetTables(targets, bases, ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, list := exportTables(targets, bases)[0], tables[0]
+	base.mustPrepare(nil)
+
+	var want [][]string
+	for w := 0; w < list.workers; w++ {
+		start, end := list.workerRange(w)
+		generate(list, w, start, end, 0, func(rows [][]interface{}) {
+			for _, row := range rows {
+				want = append(want, formatRow(row))
+			}
+		})
+	}
+	if len(want) != 300 {
+		t.Fatalf("generated %d rows, want 300", len(want))
+	}
+
+	for _, format := range []string{formatCSV, formatNDJSON} {
+		path := exportPath(t.TempDir(), base.name, format)
+		n, err := exportTable(path, format, base)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if n != int64(len(want)) {
+			t.Errorf("%s: exported %d rows, want %d", format, n, len(want))
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got [][]string
+		err = readRows(zr, format, list, func(rows [][]interface{}) {
+			if len(rows) > list.batchSize {
+				t.Errorf("%s: batch of %d rows", format, len(rows))
+			}
+			for _, row := range rows {
+				got = append(got, formatRow(row))
+			}
+		})
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: read %d rows, want %d", format, len(got), len(want))
+		}
+		for i := range want {
+			if len(got[i]) != len(want[i]) {
+				t.Fatalf("%s: row %d = %v, want %v", format, i, got[i], want[i])
+			}
+			for j := range want[i] {
+				if got[i][j] != want[i][j] {
+					t.Fatalf("%s: row %d = %v, want %v", format, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func formatRow(row []interface{}) []string {
+	s := make([]string, len(row))
+	for i, v := range row {
+		s[i] = formatValue(v)
+	}
+	return s
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/db"
+)
+
+// liveOps are the operations of -live, in the order of their weights.
+var liveOps = []string{"book", "tag", "update"}
+
+const (
+	opBook = iota
+	opTag
+	opUpdate
+)
+
+// liveConfig holds the -live-* flags.
+type liveConfig struct {
+	rate     float64
+	mix      []float64
+	duration time.Duration
+	workers  int
+}
+
+// parseMix parses -live-mix, e.g. "book=4,tag=5,update=1", into weights in
+// the order of liveOps. Operations that are not given get weight 0.
+func parseMix(spec string) ([]float64, error) {
+	weights := make([]float64, len(liveOps))
+	total := 0.0
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		i := indexOf(liveOps, name)
+		if !ok || i < 0 {
+			return nil, fmt.Errorf("want op=weight with op one of %s, got %q", strings.Join(liveOps, ", "), part)
+		}
+		w, err := strconv.ParseFloat(value, 64)
+		if err != nil || w < 0 || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("invalid weight in %q", part)
+		}
+		weights[i] = w
+		total += w
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("all weights are 0")
+	}
+	return weights, nil
+}
+
+type liveKey struct {
+	op    string
+	table string
+}
+
+// liveSamples is the number of latencies kept per operation and table.
+const liveSamples = 10000
+
+// reservoir keeps a uniform sample of at most liveSamples latencies out of
+// n, so a run without -live-duration does not grow without bound. The
+// maximum is kept exactly.
+type reservoir struct {
+	n       int64
+	max     time.Duration
+	samples []time.Duration
+}
+
+func (r *reservoir) add(rng *rand.Rand, d time.Duration) {
+	r.n++
+	if d > r.max {
+		r.max = d
+	}
+	if len(r.samples) < liveSamples {
+		r.samples = append(r.samples, d)
+	} else if i := rng.Int63n(r.n); i < liveSamples {
+		r.samples[i] = d
+	}
+}
+
+// liveWriter keeps writing to the target tables. Each operation is applied
+// to every target with the base it writes, so the variants keep the same
+// rows and compare still sees the same data in each.
+type liveWriter struct {
+	conn  *sql.DB
+	ds    datagen.Dataset
+	books []table // targets based on books
+	links []table // targets based on book_tags
+	// lastBook is the highest book id; new books continue from it
+	lastBook int64
+	done     int64
+
+	mu        sync.Mutex
+	rng       *rand.Rand // for the reservoirs
+	latencies map[liveKey]*reservoir
+	// ignored counts writes that changed no row, e.g. a tag link that
+	// already existed
+	ignored map[liveKey]int64
+	errors  map[liveKey]int64
+}
+
+// runLive writes at cfg.rate until cfg.duration has passed or the process
+// is interrupted, then prints the achieved rate and latency percentiles.
+func runLive(conn *sql.DB, tables []table, ds datagen.Dataset, cfg liveConfig) {
+	w := &liveWriter{
+		conn:      conn,
+		ds:        ds,
+		rng:       datagen.NewStream(seed, "live/latency", 0),
+		latencies: make(map[liveKey]*reservoir),
+		ignored:   make(map[liveKey]int64),
+		errors:    make(map[liveKey]int64),
+	}
+	for _, t := range tables {
+		switch t.base {
+		case "books":
+			w.books = append(w.books, t)
+		case "book_tags":
+			w.links = append(w.links, t)
+		}
+	}
+	if len(w.books) == 0 {
+		log.Fatal("-live needs a books table among -table")
+	}
+	if cfg.mix[opTag] > 0 && len(w.links) == 0 {
+		log.Fatal("-live-mix has tag links, but there is no book_tags table among -table")
+	}
+
+	// New rows reference what is in the database, not the scenario's counts
+	for _, t := range w.books {
+		var last int64
+		if err := conn.QueryRow("SELECT COALESCE(MAX(id), 0) FROM " + t.name).Scan(&last); err != nil {
+			log.Fatalf("Failed to read %s: %v", t.name, err)
+		}
+		if last > w.lastBook {
+			w.lastBook = last
+		}
+	}
+	for _, c := range []struct {
+		table string
+		n     *int
+	}{{"authors", &w.ds.Authors}, {"tags", &w.ds.Tags}} {
+		if err := conn.QueryRow("SELECT COALESCE(MAX(id), 0) FROM " + c.table).Scan(c.n); err != nil {
+			log.Fatalf("Failed to read %s: %v", c.table, err)
+		}
+	}
+	if w.lastBook == 0 || w.ds.Authors == 0 || w.ds.Tags == 0 {
+		log.Fatal("-live writes on top of existing data: seed authors, tags and books first")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+
+	names := make([]string, 0, len(w.books)+len(w.links))
+	for _, t := range append(append([]table(nil), w.books...), w.links...) {
+		names = append(names, t.name)
+	}
+	log.Printf("Live: %.0f ops/sec (%s) on %s with %d workers for %s",
+		cfg.rate, formatMix(cfg.mix), strings.Join(names, ", "), cfg.workers, formatDuration(cfg.duration))
+
+	ops := make(chan int, cfg.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			rng := datagen.NewStream(seed, "live", worker)
+			for op := range ops {
+				w.do(rng, op)
+			}
+		}(i)
+	}
+
+	started := time.Now()
+	go w.progress(ctx, started)
+	w.dispatch(ctx, cfg, ops)
+	close(ops)
+	wg.Wait()
+
+	w.summary(cfg, time.Since(started))
+}
+
+// dispatch sends operations at cfg.rate until ctx is done. When the workers
+// fall behind, the sends block and the achieved rate drops below the
+// target; the lost time is not made up with a burst.
+func (w *liveWriter) dispatch(ctx context.Context, cfg liveConfig, ops chan<- int) {
+	pick := datagen.Weighted{Weights: cfg.mix}
+	rng := datagen.NewStream(seed, "live/mix", 0)
+	interval := time.Duration(float64(time.Second) / cfg.rate)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	for {
+		if d := time.Until(next); d > 0 {
+			timer.Reset(d)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case ops <- pick.Int(rng, len(liveOps)):
+		}
+		next = next.Add(interval)
+		if time.Since(next) > 100*time.Millisecond {
+			next = time.Now()
+		}
+	}
+}
+
+// do runs one operation on every target table it writes.
+func (w *liveWriter) do(rng *rand.Rand, op int) {
+	now := time.Now().In(w.ds.Zone).Truncate(time.Second)
+	switch op {
+	case opBook:
+		id := atomic.AddInt64(&w.lastBook, 1)
+		row := w.ds.Book(rng, int(id-1))
+		row[3] = now
+		for _, t := range w.books {
+			w.exec("book", t, false, append(append([]interface{}(nil), row...), t.extraRow(rng)...)...)
+		}
+	case opTag:
+		book := 1 + rng.Int63n(atomic.LoadInt64(&w.lastBook))
+		tag := 1 + rng.Intn(w.ds.Tags)
+		for _, t := range w.links {
+			// A book that already has the tag is not an error
+			w.exec("tag", t, true, append([]interface{}{book, tag, now}, t.extraRow(rng)...)...)
+		}
+	case opUpdate:
+		id := 1 + rng.Int63n(atomic.LoadInt64(&w.lastBook))
+		title := w.ds.Book(rng, int(id-1))[1]
+		for _, t := range w.books {
+			w.record("update", t.name, func() (sql.Result, error) {
+				return w.conn.Exec("UPDATE "+t.name+" SET title = ? WHERE id = ?", title, id)
+			})
+		}
+	}
+	atomic.AddInt64(&w.done, 1)
+}
+
+// exec inserts one row into t.
+func (w *liveWriter) exec(op string, t table, ignore bool, row ...interface{}) {
+	verb := "INSERT"
+	if ignore {
+		verb = "INSERT IGNORE"
+	}
+	query := verb + " INTO " + t.name + " (" + strings.Join(t.columns, ", ") + ") VALUES (" +
+		strings.TrimSuffix(strings.Repeat("?, ", len(t.columns)), ", ") + ")"
+	w.record(op, t.name, func() (sql.Result, error) {
+		return w.conn.Exec(query, row...)
+	})
+}
+
+// record runs fn with the same retries as seeding and records its latency,
+// retries included, or its error. A write that changed no row, such as an
+// ignored duplicate, is counted apart so it does not inflate the write rate.
+// Operations run to completion after ctx is done so the last ones are not
+// counted as errors.
+func (w *liveWriter) record(op, table string, fn func() (sql.Result, error)) {
+	start := time.Now()
+	var affected int64
+	err := db.Retry(context.Background(), "live "+op, func() error {
+		res, err := fn()
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		return err
+	})
+	elapsed := time.Since(start)
+
+	key := liveKey{op, table}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case err != nil:
+		if w.errors[key]++; w.errors[key] <= 3 {
+			log.Printf("Live %s on %s failed: %v", op, table, err)
+		}
+	case affected == 0:
+		w.ignored[key]++
+	default:
+		r := w.latencies[key]
+		if r == nil {
+			r = &reservoir{}
+			w.latencies[key] = r
+		}
+		r.add(w.rng, elapsed)
+	}
+}
+
+func (w *liveWriter) progress(ctx context.Context, started time.Time) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	last, lastAt := int64(0), started
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			done := atomic.LoadInt64(&w.done)
+			w.mu.Lock()
+			var errors int64
+			for _, n := range w.errors {
+				errors += n
+			}
+			w.mu.Unlock()
+			log.Printf("Live: %d ops | Rate: %.0f/sec | Errors: %d", done, float64(done-last)/now.Sub(lastAt).Seconds(), errors)
+			last, lastAt = done, now
+		}
+	}
+}
+
+// summary prints the achieved rate and the latency percentiles by operation
+// and table. Count and rate are the writes that changed a row; the
+// percentiles come from a sample of at most liveSamples of them.
+func (w *liveWriter) summary(cfg liveConfig, elapsed time.Duration) {
+	done := atomic.LoadInt64(&w.done)
+	log.Printf("Live finished after %v: %d ops, %.1f ops/sec (target %.0f)",
+		elapsed.Round(time.Millisecond), done, float64(done)/elapsed.Seconds(), cfg.rate)
+
+	seen := make(map[liveKey]bool)
+	var keys []liveKey
+	for _, m := range []map[liveKey]int64{w.ignored, w.errors} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	for k := range w.latencies {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].op != keys[j].op {
+			return indexOf(liveOps, keys[i].op) < indexOf(liveOps, keys[j].op)
+		}
+		return keys[i].table < keys[j].table
+	})
+
+	fmt.Printf("\n%-8s %-28s %10s %10s %10s %10s %10s %10s %8s %8s\n",
+		"op", "table", "count", "rate/sec", "p50", "p95", "p99", "max", "ignored", "errors")
+	for _, k := range keys {
+		var n int64
+		var max time.Duration
+		var lat []time.Duration
+		if r := w.latencies[k]; r != nil {
+			n, max, lat = r.n, r.max, r.samples
+		}
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		fmt.Printf("%-8s %-28s %10d %10.1f %10v %10v %10v %10v %8d %8d\n",
+			k.op, k.table, n, float64(n)/elapsed.Seconds(),
+			percentile(lat, 50).Round(time.Microsecond),
+			percentile(lat, 95).Round(time.Microsecond),
+			percentile(lat, 99).Round(time.Microsecond),
+			max.Round(time.Microsecond),
+			w.ignored[k], w.errors[k])
+	}
+}
+
+// percentile returns the p-th percentile (nearest rank) of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func formatMix(mix []float64) string {
+	parts := make([]string, len(liveOps))
+	for i, op := range liveOps {
+		parts[i] = op + "=" + strconv.FormatFloat(mix[i], 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "until interrupted"
+	}
+	return d.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	got, err := parseMix("book=4, update=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{4, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMix = %v, want %v", got, want)
+	}
+	if got := formatMix(got); got != "book=4,tag=0,update=1" {
+		t.Errorf("formatMix = %q", got)
+	}
+
+	for _, spec := range []string{"", "book", "delete=1", "book=-1", "book=x", "book=0,tag=0"} {
+		if _, err := parseMix(spec); err == nil {
+			t.Errorf("parseMix(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 95: 95 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("p%v = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("empty p50 = %v", got)
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var r reservoir
+	for i := 1; i <= 3*liveSamples; i++ {
+		r.add(rng, time.Duration(i))
+	}
+	if r.n != 3*liveSamples || len(r.samples) != liveSamples || r.max != 3*liveSamples {
+		t.Errorf("n %d, %d samples, max %v", r.n, len(r.samples), r.max)
+	}
+	// A uniform sample of 1..3N has about a third of its values up to N
+	low := 0
+	for _, d := range r.samples {
+		if d <= liveSamples {
+			low++
+		}
+	}
+	if low < liveSamples/4 || low > liveSamples*5/12 {
+		t.Errorf("%d of %d samples from the first third", low, liveSamples)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	methodInsert   = "insert"
+	methodLoadData = "load-data"
+
+	// datetimeLayout writes DATETIMEs as MySQL reads them
+	datetimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+// batchWriter writes one batch of rows in tx.
+type batchWriter func(tx *sql.Tx, rows [][]interface{}) (sql.Result, error)
+
+// newBatchWriter returns the writer of one worker for method.
+func newBatchWriter(method string, t table, workerID int) batchWriter {
+	if method == methodLoadData {
+		return loadDataWriter(t, workerID)
+	}
+	return insertWriter(t)
+}
+
+// insertWriter writes a batch as one multi-row INSERT.
+func insertWriter(t table) batchWriter {
+	prefix := "INSERT INTO " + t.name + " (" + strings.Join(t.columns, ", ") + ") VALUES "
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(t.columns)), ", ") + ")"
+
+	values := make([]string, 0, t.batchSize)
+	args := make([]interface{}, 0, t.batchSize*len(t.columns))
+	return func(tx *sql.Tx, rows [][]interface{}) (sql.Result, error) {
+		values, args = values[:0], args[:0]
+		for _, row := range rows {
+			values = append(values, placeholders)
+			args = append(args, row...)
+		}
+		return tx.Exec(prefix+strings.Join(values, ","), args...)
+	}
+}
+
+// loadDataWriter writes a batch with LOAD DATA LOCAL INFILE. The rows are
+// encoded as CSV in memory and handed to the driver as the worker's
+// registered reader, so nothing touches the disk; a retry reads the same
+// buffer again. The reader is registered once per worker and reads whatever
+// batch the worker is loading: the driver calls it from inside tx.Exec.
+//
+// With LOCAL, MySQL skips duplicate keys and truncates bad values with a
+// warning instead of failing like the INSERT does, so those warnings fail the
+// batch. Other warnings are logged.
+func loadDataWriter(t table, workerID int) batchWriter {
+	reader := fmt.Sprintf("seed/%s/%d", t.name, workerID)
+	query := loadDataQuery(t.name, t.columns, "Reader::"+reader)
+
+	var buf []byte
+	mysql.RegisterReaderHandler(reader, func() io.Reader { return bytes.NewReader(buf) })
+	return func(tx *sql.Tx, rows [][]interface{}) (sql.Result, error) {
+		buf = buf[:0]
+		for _, row := range rows {
+			buf = appendCSV(buf, row)
+		}
+
+		res, err := tx.Exec(query)
+		if err != nil {
+			return nil, err
+		}
+		if err := loadWarnings(tx); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		return res, nil
+	}
+}
+
+func loadDataQuery(table string, columns []string, file string) string {
+	return "LOAD DATA LOCAL INFILE '" + file + "' INTO TABLE " + table +
+		` CHARACTER SET utf8mb4 FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY '\\'` +
+		` LINES TERMINATED BY '\n' (` + strings.Join(columns, ", ") + ")"
+}
+
+// rowWarnings are the warnings LOAD DATA gives for a row it skipped or
+// stored differently from the file: the errors an INSERT would have failed
+// with.
+var rowWarnings = map[int]bool{
+	1048: true, // column cannot be null
+	1062: true, // duplicate entry
+	1261: true, // row doesn't contain data for all columns
+	1262: true, // row truncated, it contained more data than columns
+	1263: true, // NULL supplied to a NOT NULL column
+	1264: true, // out of range value
+	1265: true, // data truncated
+	1292: true, // incorrect (datetime) value
+	1366: true, // incorrect (integer, string) value
+}
+
+// loadWarnings returns an error for the first row warning of the last
+// statement in tx, and logs the other warnings.
+func loadWarnings(tx *sql.Tx) error {
+	var count int
+	if err := tx.QueryRow("SELECT @@warning_count").Scan(&count); err != nil || count == 0 {
+		return err
+	}
+	rows, err := tx.Query("SHOW WARNINGS")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var failed error
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return err
+		}
+		if !rowWarnings[code] {
+			log.Printf("LOAD DATA: %s %d: %s", level, code, message)
+			continue
+		}
+		if failed == nil {
+			failed = fmt.Errorf("LOAD DATA gave %d warnings, first row warning: %s %d: %s", count, level, code, message)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return failed
+}
+
+// appendCSV appends row as one line in the format of loadDataQuery.
+// DATETIMEs are written as they are: the generator creates them in the
+// connection's time zone, which is where the driver would convert them to
+// for an INSERT.
+func appendCSV(buf []byte, row []interface{}) []byte {
+	for i, v := range row {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		switch v := v.(type) {
+		case nil:
+			buf = append(buf, `\N`...)
+		case int:
+			buf = strconv.AppendInt(buf, int64(v), 10)
+		case int64:
+			buf = strconv.AppendInt(buf, v, 10)
+		case float64:
+			buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+		case bool:
+			if v {
+				buf = append(buf, '1')
+			} else {
+				buf = append(buf, '0')
+			}
+		case time.Time:
+			buf = v.AppendFormat(buf, datetimeLayout)
+		case string:
+			buf = appendQuoted(buf, v)
+		case []byte:
+			buf = appendQuoted(buf, string(v))
+		default:
+			buf = appendQuoted(buf, fmt.Sprint(v))
+		}
+	}
+	return append(buf, '\n')
+}
+
+func appendQuoted(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case 0:
+			buf = append(buf, '\\', '0')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"')
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppendCSV(t *testing.T) {
+	at := time.Date(2024, 7, 1, 9, 30, 0, 0, time.UTC)
+	row := []interface{}{1, int64(2), "Book \"one\", a\\b\nc", at, nil, true, 0.5}
+	want := `1,2,"Book \"one\", a\\b\nc",2024-07-01 09:30:00,\N,1,0.5` + "\n"
+	if got := string(appendCSV(nil, row)); got != want {
+		t.Errorf("appendCSV = %q, want %q", got, want)
+	}
+
+	frac := at.Add(1500 * time.Microsecond)
+	if got := string(appendCSV(nil, []interface{}{frac})); got != "2024-07-01 09:30:00.0015\n" {
+		t.Errorf("fractional time = %q", got)
+	}
+}
@@ -1,103 +1,348 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
 	"log"
 	"math/rand"
-	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/sters/try-mysql-partitioning/config"
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/partman"
 )
 
 const (
-	defaultAuthors   = 10000
-	defaultBooks     = 1000000
-	defaultTags      = 1000
-	defaultBookTags  = 5000000
+	defaultSeed       = 1
+	defaultAuthors    = 10000
+	defaultBooks      = 1000000
+	defaultTags       = 1000
+	defaultBookTags   = 5000000
 	defaultAuthorTags = 50000
+	defaultEventDays  = 90
 
-	bulkSize    = 5000  // Records per INSERT statement
-	workerCount = 8     // Parallel workers
+	defaultBatchSize = 5000 // Rows per INSERT / LOAD DATA
+	defaultWorkers   = 8    // Parallel workers of the large tables
 )
 
 var (
 	totalInserted int64
 	totalTarget   int64
 	startTime     time.Time
+
+	// seed is the root of every worker's random stream
+	seed int64
+	// runFingerprint identifies the generated data in checkpoints
+	runFingerprint string
+	// method is how batches are written: methodInsert or methodLoadData
+	method string
 )
 
 func main() {
-	host := flag.String("host", getEnv("DB_HOST", "localhost"), "Database host")
-	port := flag.String("port", getEnv("DB_PORT", "3306"), "Database port")
-	user := flag.String("user", getEnv("DB_USER", "app"), "Database user")
-	password := flag.String("password", getEnv("DB_PASSWORD", "app"), "Database password")
-	dbname := flag.String("db", getEnv("DB_NAME", "bookdb"), "Database name")
-
-	numAuthors := flag.Int("authors", defaultAuthors, "Number of authors to insert")
-	numBooks := flag.Int("books", defaultBooks, "Number of books to insert")
-	numTags := flag.Int("tags", defaultTags, "Number of tags to insert")
-	numBookTags := flag.Int("book-tags", defaultBookTags, "Number of book-tag associations")
-	numAuthorTags := flag.Int("author-tags", defaultAuthorTags, "Number of author-tag associations")
+	defaults := config.Default()
+	defaults.MaxOpenConns = defaultWorkers * 2
+	defaults.MaxIdleConns = defaultWorkers
+	dbFlags := config.RegisterFlags(flag.CommandLine, defaults)
+
+	// The dataset flags override the scenario; see Scenario.override
+	scenarioPath := flag.String("scenario", "", "Seed the dataset described by this JSON file (a scenario, or the manifest of an earlier run); flags given explicitly override it")
+	flag.Int("authors", defaultAuthors, "Number of authors to insert")
+	flag.Int("books", defaultBooks, "Number of books to insert")
+	flag.Int("tags", defaultTags, "Number of tags to insert")
+	flag.Int("book-tags", defaultBookTags, "Number of book-tag associations")
+	flag.Int("author-tags", defaultAuthorTags, "Number of author-tag associations")
+	flag.Int("events", 0, "Number of book_events (views/purchases) to insert")
+	flag.Int("event-days", defaultEventDays, "Spread book_events over this many days up to -event-end")
+	flag.String("event-end", "", "End of the book_events window, YYYY-MM-DD exclusive (default: the end of the catalog window, 2024-12-30)")
+	flag.Int("batch-size", defaultBatchSize, "Rows per INSERT / LOAD DATA, for every table")
+	flag.Int("workers", defaultWorkers, "Parallel workers for every table (default: 8 for books, book_tags and book_events, 1 for the rest)")
+	flag.String("method", methodInsert, "How to write batches: insert (multi-row INSERT) or load-data (LOAD DATA LOCAL INFILE; needs local_infile=ON)")
+	flag.String("table", "", "Tables to seed: comma-separated tables or variant groups (see /variants), or all (default: the base tables)")
+	flag.Int64("seed", defaultSeed, "Random seed; the same seed and flags generate the same rows (0 = the default)")
+	dists := datagen.Dists{}
+	flag.Var(dists, "dist", "Distribution of a column, table.column=uniform|zipf:S|normal:MEAN,STDDEV|growth:RATE|weights:W0,W1,... (repeatable)")
 	truncate := flag.Bool("truncate", false, "Truncate tables before seeding")
+	resume := flag.Bool("resume", false, "Continue an interrupted run from its checkpoints; the scenario must be the same")
+	create := flag.Bool("create", false, "(Re)create the partitioned variant tables from their setup scripts, without copying data")
+	manifestPath := flag.String("manifest", "seed-manifest.json", "Write the scenario and row counts to this file (empty = don't)")
+	live := flag.Bool("live", false, "Keep writing to the existing data at -live-rate instead of seeding, and report the latencies")
+	liveRate := flag.Float64("live-rate", 100, "Operations per second in -live mode")
+	liveMix := flag.String("live-mix", "book=4,tag=5,update=1", "Weights of the -live operations: book (insert a book), tag (link a tag), update (change a title)")
+	liveDuration := flag.Duration("live-duration", time.Minute, "How long -live runs (0 = until interrupted)")
+	liveWorkers := flag.Int("live-workers", defaultWorkers, "Concurrent writers in -live mode")
+	exportDir := flag.String("export", "", "Write the base tables of the targets to gzip-compressed files in this directory instead of seeding; needs no database")
+	importDir := flag.String("import", "", "Load the files written by -export from this directory into the target tables (any variant); the scenario defaults to the directory's manifest")
+	format := flag.String("format", formatCSV, "File format of -export: csv or ndjson")
 
 	flag.Parse()
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local&maxAllowedPacket=256000000",
-		*user, *password, *host, *port, *dbname)
+	cfg := dbFlags.MustLoad()
+	cfg.MaxAllowedPacket = 256000000
+
+	// Generate DATETIMEs in the connection's location so the stored values
+	// are the same on every machine
+	zone, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		log.Fatalf("Invalid time zone: %v", err)
+	}
+
+	// Imported files are generated from the scenario of their export
+	if *importDir != "" && *scenarioPath == "" {
+		if path := filepath.Join(*importDir, exportManifest); fileExists(path) {
+			*scenarioPath = path
+		}
+	}
+
+	sc := defaultScenario()
+	if *scenarioPath != "" {
+		if sc, err = loadScenario(*scenarioPath); err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+	}
+	if err := sc.override(dists, zone); err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if err := sc.resolve(zone); err != nil {
+		log.Fatalf("Invalid scenario: %v", err)
+	}
+	seed, method = sc.Seed, sc.Method
+	runFingerprint = sc.fingerprint()
 
-	db, err := sql.Open("mysql", dsn)
+	targets, err := resolveTargets(sc.Targets)
+	if err != nil {
+		log.Fatalf("Invalid targets: %v", err)
+	}
+	ds, err := sc.dataset(zone)
+	if err != nil {
+		log.Fatalf("Invalid scenario: %v", err)
+	}
+	var mix []float64
+	if *live {
+		if mix, err = parseMix(*liveMix); err != nil {
+			log.Fatalf("Invalid -live-mix: %v", err)
+		}
+		if *liveRate <= 0 || *liveWorkers < 1 {
+			log.Fatal("-live-rate and -live-workers must be positive")
+		}
+		if *truncate || *resume {
+			log.Fatal("-live writes on top of the existing data; it cannot be combined with -truncate or -resume")
+		}
+	}
+
+	conns := sc.maxWorkers()
+	if *live && *liveWorkers > conns {
+		conns = *liveWorkers
+	}
+	if cfg.MaxOpenConns < 2*conns {
+		cfg.MaxOpenConns, cfg.MaxIdleConns = 2*conns, conns
+	}
+
+	if *resume && (*truncate || *create) {
+		log.Fatal("-resume cannot be combined with -truncate or -create")
+	}
+	if *importDir != "" && (*resume || *live) {
+		log.Fatal("-import cannot be combined with -resume or -live")
+	}
+
+	if *exportDir != "" {
+		if *importDir != "" || *live || *resume || *truncate || *create {
+			log.Fatal("-export only writes files; it cannot be combined with -import, -live, -resume, -truncate or -create")
+		}
+		if *format != formatCSV && *format != formatNDJSON {
+			log.Fatalf("Invalid -format %q: use %s or %s", *format, formatCSV, formatNDJSON)
+		}
+		if err := resolveReferences(nil, sc, &ds); err != nil {
+			log.Fatal(err)
+		}
+		runExport(*exportDir, *format, sc, exportTables(targets, baseTables(sc, ds)))
+		return
+	}
+
+	db, err := cfg.Open()
 	if err != nil {
 		log.Fatalf("Failed to connect: %v", err)
 	}
 	defer db.Close()
 
-	db.SetMaxOpenConns(workerCount * 2)
-	db.SetMaxIdleConns(workerCount)
-
 	if err := db.Ping(); err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 
-	log.Println("Connected to database")
+	log.Printf("Connected to database (seed %d, method %s)", seed, method)
+
+	// Tables reference the existing rows of the tables that are not seeded
+	if err := resolveReferences(db, sc, &ds); err != nil {
+		log.Fatal(err)
+	}
 
-	if *truncate {
-		log.Println("Truncating tables...")
-		truncateTables(db)
+	bases := baseTables(sc, ds)
+	tables, err := targetTables(targets, bases, ds)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *create {
+		createVariants(db, targets)
+	}
+	if missing := missingTables(db, tables); len(missing) > 0 {
+		log.Fatalf("%s not found; create them with -create", strings.Join(missing, ", "))
 	}
 
-	totalTarget = int64(*numAuthors + *numBooks + *numTags + *numBookTags + *numAuthorTags)
+	if *live {
+		runLive(db, tables, ds, liveConfig{rate: *liveRate, mix: mix, duration: *liveDuration, workers: *liveWorkers})
+		return
+	}
+
+	if err := ensureCheckpoints(db); err != nil {
+		log.Fatalf("Failed to create seed_checkpoints: %v", err)
+	}
+
+	checkpoints := make(map[string]map[int]checkpoint)
+	if *resume {
+		checkpoints = loadAllCheckpoints(db, tables)
+	} else {
+		if *truncate {
+			log.Println("Truncating tables...")
+			truncateTables(db, tables)
+		} else if names := nonEmpty(db, tables); len(names) > 0 {
+			log.Fatalf("%s already have rows; use -truncate, or -resume to continue an interrupted run", strings.Join(names, ", "))
+		}
+		for _, t := range tables {
+			if err := clearCheckpoints(db, t.name); err != nil {
+				log.Fatalf("Failed to clear checkpoints: %v", err)
+			}
+		}
+	}
+
+	for _, t := range tables {
+		totalTarget += int64(t.count)
+		for _, cp := range checkpoints[t.name] {
+			totalTarget -= cp.rows
+		}
+	}
 	startTime = time.Now()
 
+	manifest := newManifest(sc, startTime)
+	if *manifestPath != "" {
+		if err := manifest.write(*manifestPath); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+	}
+
 	// Progress reporter
 	done := make(chan struct{})
 	go progressReporter(done)
 
 	// Seed in order
-	seedAuthors(db, *numAuthors)
-	seedTags(db, *numTags)
-	seedBooks(db, *numBooks, *numAuthors)
-	seedBookTags(db, *numBookTags, *numBooks, *numTags)
-	seedAuthorTags(db, *numAuthorTags, *numAuthors, *numTags)
+	inserted := make(map[string]int64)
+	rates := make(map[string]float64)
+	for _, t := range tables {
+		if *importDir != "" {
+			inserted[t.name], rates[t.name] = importTable(db, *importDir, t)
+		} else {
+			inserted[t.name], rates[t.name] = seedTable(db, t, checkpoints[t.name])
+		}
+	}
 
 	close(done)
 	time.Sleep(100 * time.Millisecond)
 
 	elapsed := time.Since(startTime)
-	log.Printf("Completed! Total time: %v, Records: %d, Rate: %.0f/sec",
-		elapsed.Round(time.Second), totalInserted, float64(totalInserted)/elapsed.Seconds())
+	log.Printf("Completed! Total time: %v, Records: %d, Rate: %.0f/sec (%s)",
+		elapsed.Round(time.Second), totalInserted, float64(totalInserted)/elapsed.Seconds(), method)
+
+	if *manifestPath != "" {
+		manifest.finish(db, tables, inserted, rates, elapsed)
+		if err := manifest.write(*manifestPath); err != nil {
+			log.Fatalf("Failed to write manifest: %v", err)
+		}
+		log.Printf("Manifest written to %s", *manifestPath)
+	}
 }
 
-func truncateTables(db *sql.DB) {
-	tables := []string{"book_tags", "author_tags", "books", "tags", "authors"}
-	for _, t := range tables {
-		if _, err := db.Exec("TRUNCATE TABLE " + t); err != nil {
-			log.Printf("Warning: failed to truncate %s: %v", t, err)
+// baseTables returns the generators of the base tables, in the order of
+// generated.
+func baseTables(sc Scenario, ds datagen.Dataset) []table {
+	events := rowTable("book_events", datagen.EventColumns, sc.Tables["book_events"], ds.Event)
+	events.setup = func(conn *sql.DB) error {
+		// Daily partitions for the whole window must exist before inserting
+		added, err := partman.EnsureRange(context.Background(), conn, "book_events", partman.Day, ds.EventStart(), ds.EventEnd)
+		if err == nil {
+			log.Printf("book_events spread over %d days (%d partitions added)", ds.EventDays, len(added))
+		}
+		return err
+	}
+
+	return []table{
+		rowTable("authors", datagen.AuthorColumns, sc.Tables["authors"], ds.Author),
+		rowTable("tags", datagen.TagColumns, sc.Tables["tags"], ds.Tag),
+		rowTable("books", datagen.BookColumns, sc.Tables["books"], ds.Book),
+		linkTable(ds.BookTags(), datagen.BookTagColumns, sc.Tables["book_tags"]),
+		linkTable(ds.AuthorTags(), datagen.AuthorTagColumns, sc.Tables["author_tags"]),
+		events,
+	}
+}
+
+// reference is a table whose ids another table draws, and the Dataset field
+// holding the number of those ids.
+type reference struct {
+	table string
+	ids   *int
+}
+
+// references returns the tables each table draws ids from.
+func references(ds *datagen.Dataset) map[string][]reference {
+	authors, books, tags := reference{"authors", &ds.Authors}, reference{"books", &ds.Books}, reference{"tags", &ds.Tags}
+	return map[string][]reference{
+		"books":       {authors},
+		"book_tags":   {books, tags},
+		"author_tags": {authors, tags},
+		"book_events": {books},
+	}
+}
+
+// resolveReferences makes sure every table with rows to seed has ids to
+// reference. The ids of a table that is not seeded are the ones in db, 1 to
+// MAX(id); without db (-export) there are none.
+func resolveReferences(db *sql.DB, sc Scenario, ds *datagen.Dataset) error {
+	refs := references(ds)
+	for _, name := range generated {
+		if t := sc.Tables[name]; t == nil || t.Count == 0 {
+			continue
+		}
+		for _, ref := range refs[name] {
+			if *ref.ids > 0 {
+				continue
+			}
+			if db != nil {
+				if err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM " + ref.table).Scan(ref.ids); err != nil {
+					return fmt.Errorf("%s: reading the ids of %s: %w", name, ref.table, err)
+				}
+			}
+			if *ref.ids == 0 {
+				return fmt.Errorf("%s need %s: seed them with -%s or first", name, ref.table, ref.table)
+			}
+		}
+	}
+	return nil
+}
+
+// truncateTables empties the tables that are about to be seeded, so e.g. an
+// events-only run keeps the catalog.
+func truncateTables(db *sql.DB, ts []table) {
+	for _, t := range ts {
+		if t.count == 0 {
+			continue
+		}
+		if _, err := db.Exec("TRUNCATE TABLE " + t.name); err != nil {
+			log.Printf("Warning: failed to truncate %s: %v", t.name, err)
 		}
 	}
 }
@@ -123,239 +368,259 @@ func progressReporter(done chan struct{}) {
 	}
 }
 
-func seedAuthors(db *sql.DB, count int) {
-	log.Printf("Seeding %d authors...", count)
-
-	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
-
-	for i := 0; i < count; i += bulkSize {
-		end := i + bulkSize
-		if end > count {
-			end = count
-		}
-
-		values := make([]string, 0, end-i)
-		args := make([]interface{}, 0, (end-i)*2)
-
-		for j := i; j < end; j++ {
-			values = append(values, "(?, ?)")
-			createdAt := baseTime.Add(time.Duration(rand.Intn(5*365*24)) * time.Hour)
-			args = append(args, fmt.Sprintf("Author %d", j+1), createdAt)
-		}
-
-		query := "INSERT INTO authors (name, created_at) VALUES " + strings.Join(values, ",")
-		if _, err := db.Exec(query, args...); err != nil {
-			log.Printf("Error inserting authors: %v", err)
-		}
+// table is one table to seed. Its rows are generated in units, one row or
+// all links of one owner, and the units are split into contiguous ranges,
+// one per worker. Each worker draws from its own stream, so the rows do not
+// depend on how the workers interleave.
+type table struct {
+	name string
+	// base is the table whose rows are generated; a variant gets the same
+	// rows as its base.
+	base    string
+	columns []string
+	// count is the number of rows, generated in units units.
+	count     int
+	units     int
+	workers   int
+	batchSize int
+	// prepare, if set, runs before the rows are generated.
+	prepare func() error
+	// setup, if set, prepares the database before the rows are inserted.
+	setup func(conn *sql.DB) error
+	// gen emits the rows of one unit.
+	gen func(rng *rand.Rand, unit int, emit func(row []interface{}))
+	// extra generates the variant's extra columns, which follow the base
+	// columns in columns.
+	extra []func(rng *rand.Rand) interface{}
+}
 
-		atomic.AddInt64(&totalInserted, int64(end-i))
+// rowTable generates one row per unit.
+func rowTable(name string, columns []string, ts *TableScenario, row func(rng *rand.Rand, i int) []interface{}) table {
+	return table{
+		name: name, base: name, columns: columns,
+		count: ts.Count, units: ts.Count, workers: ts.Workers, batchSize: ts.BatchSize,
+		gen: func(rng *rand.Rand, i int, emit func([]interface{})) {
+			emit(row(rng, i))
+		},
 	}
 }
 
-func seedTags(db *sql.DB, count int) {
-	log.Printf("Seeding %d tags...", count)
-
-	tagCategories := []string{"Fiction", "Non-Fiction", "Science", "History", "Art", "Technology",
-		"Philosophy", "Biography", "Travel", "Cooking", "Health", "Business", "Education", "Sports"}
-
-	for i := 0; i < count; i += bulkSize {
-		end := i + bulkSize
-		if end > count {
-			end = count
-		}
-
-		values := make([]string, 0, end-i)
-		args := make([]interface{}, 0, end-i)
-
-		for j := i; j < end; j++ {
-			values = append(values, "(?)")
-			category := tagCategories[rand.Intn(len(tagCategories))]
-			args = append(args, fmt.Sprintf("%s-%d", category, j+1))
-		}
-
-		query := "INSERT INTO tags (name) VALUES " + strings.Join(values, ",")
-		if _, err := db.Exec(query, args...); err != nil {
-			log.Printf("Error inserting tags: %v", err)
-		}
-
-		atomic.AddInt64(&totalInserted, int64(end-i))
+// linkTable generates the links of one owner per unit. The number of links
+// per owner is allocated once, also when variants share the table.
+func linkTable(l datagen.Links, columns []string, ts *TableScenario) table {
+	var (
+		once sync.Once
+		per  []int32
+		err  error
+	)
+	return table{
+		name: l.Table, base: l.Table, columns: columns,
+		count: ts.Count, units: l.Owners, workers: ts.Workers, batchSize: ts.BatchSize,
+		prepare: func() error {
+			once.Do(func() { per, err = l.Allocate(seed, ts.Count) })
+			return err
+		},
+		gen: func(rng *rand.Rand, owner int, emit func([]interface{})) {
+			if per[owner] == 0 {
+				return
+			}
+			for _, row := range l.Rows(rng, owner, int(per[owner])) {
+				emit(row)
+			}
+		},
 	}
 }
 
-func seedBooks(db *sql.DB, count, numAuthors int) {
-	log.Printf("Seeding %d books with %d workers...", count, workerCount)
-
-	booksPerWorker := count / workerCount
-	var wg sync.WaitGroup
-
-	for w := 0; w < workerCount; w++ {
-		wg.Add(1)
-		start := w * booksPerWorker
-		end := start + booksPerWorker
-		if w == workerCount-1 {
-			end = count
-		}
-
-		go func(workerID, start, end int) {
-			defer wg.Done()
-			insertBooks(db, workerID, start, end, numAuthors)
-		}(w, start, end)
+// workerRange returns the units [start, end) of worker w. The last worker
+// takes the remainder.
+func (t table) workerRange(w int) (start, end int) {
+	per := t.units / t.workers
+	start, end = w*per, (w+1)*per
+	if w == t.workers-1 {
+		end = t.units
 	}
-
-	wg.Wait()
+	return start, end
 }
 
-func insertBooks(db *sql.DB, workerID, start, end, numAuthors int) {
-	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
-	titles := []string{"The Art of", "Introduction to", "Advanced", "Complete Guide to",
-		"Mastering", "Understanding", "Practical", "Essential", "Modern", "Classic"}
-	subjects := []string{"Programming", "Design", "Science", "History", "Mathematics",
-		"Physics", "Chemistry", "Biology", "Economics", "Philosophy"}
-
-	for i := start; i < end; i += bulkSize {
-		batchEnd := i + bulkSize
-		if batchEnd > end {
-			batchEnd = end
-		}
+// worker returns the worker that generates unit.
+func (t table) worker(unit int) int {
+	per := t.units / t.workers
+	if per == 0 {
+		return t.workers - 1
+	}
+	if w := unit / per; w < t.workers {
+		return w
+	}
+	return t.workers - 1
+}
 
-		values := make([]string, 0, batchEnd-i)
-		args := make([]interface{}, 0, (batchEnd-i)*3)
-
-		for j := i; j < batchEnd; j++ {
-			values = append(values, "(?, ?, ?)")
-			title := fmt.Sprintf("%s %s Vol.%d",
-				titles[rand.Intn(len(titles))],
-				subjects[rand.Intn(len(subjects))],
-				j+1)
-			authorID := rand.Intn(numAuthors) + 1
-			createdAt := baseTime.Add(time.Duration(rand.Intn(5*365*24)) * time.Hour)
-			args = append(args, title, authorID, createdAt)
+// mustPrepare runs t's prepare and, with a database, its setup.
+func (t table) mustPrepare(conn *sql.DB) {
+	if t.prepare != nil {
+		if err := t.prepare(); err != nil {
+			log.Fatalf("Failed to prepare %s: %v", t.name, err)
 		}
-
-		query := "INSERT INTO books (title, author_id, created_at) VALUES " + strings.Join(values, ",")
-		if _, err := db.Exec(query, args...); err != nil {
-			log.Printf("Worker %d: Error inserting books: %v", workerID, err)
+	}
+	if t.setup != nil && conn != nil {
+		if err := t.setup(conn); err != nil {
+			log.Fatalf("Failed to set up %s: %v", t.name, err)
 		}
-
-		atomic.AddInt64(&totalInserted, int64(batchEnd-i))
 	}
 }
 
-func seedBookTags(db *sql.DB, count, numBooks, numTags int) {
-	log.Printf("Seeding %d book_tags with %d workers...", count, workerCount)
+// seedTable inserts t, continuing from cps. It returns the number of rows
+// in t including those inserted before a resume, and the rows per second
+// inserted by this run. Inserting other than t.count rows is fatal.
+func seedTable(db *sql.DB, t table, cps map[int]checkpoint) (int64, float64) {
+	if t.count == 0 {
+		return 0, 0
+	}
+	t.mustPrepare(db)
+	var inserted int64
+	for _, cp := range cps {
+		inserted += cp.rows
+	}
+	if inserted > 0 {
+		log.Printf("Resuming %s at %d of %d rows with %d workers...", t.name, inserted, t.count, t.workers)
+	} else {
+		log.Printf("Seeding %d %s with %d workers...", t.count, t.name, t.workers)
+	}
+
+	resumed := inserted
+	tableStart := time.Now()
 
-	tagsPerWorker := count / workerCount
 	var wg sync.WaitGroup
 
-	for w := 0; w < workerCount; w++ {
+	for w := 0; w < t.workers; w++ {
 		wg.Add(1)
-		start := w * tagsPerWorker
-		end := start + tagsPerWorker
-		if w == workerCount-1 {
-			end = count
+		start, end := t.workerRange(w)
+		if cps[w].done {
+			wg.Done()
+			continue
 		}
 
-		go func(workerID, start, end int) {
+		go func(workerID, start, end int, skip int64) {
 			defer wg.Done()
-			insertBookTags(db, workerID, start, end, numBooks, numTags)
-		}(w, start, end)
+			atomic.AddInt64(&inserted, insertRows(db, t, workerID, start, end, skip))
+		}(w, start, end, cps[w].rows)
 	}
 
 	wg.Wait()
-}
-
-func insertBookTags(db *sql.DB, workerID, start, end, numBooks, numTags int) {
-	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
 
-	// Use a set to track inserted pairs within batch
-	inserted := make(map[string]bool)
+	elapsed := time.Since(tableStart)
+	rate := float64(inserted-resumed) / elapsed.Seconds()
+	log.Printf("Seeded %s: %d rows in %v, %.0f rows/sec (%s)",
+		t.name, inserted-resumed, elapsed.Round(time.Millisecond), rate, method)
+	// The server can skip rows without an error (e.g. LOAD DATA on a
+	// duplicate key); a dataset of the wrong size must not pass as seeded.
+	if inserted != int64(t.count) {
+		log.Fatalf("Seeding %s: %d rows inserted, %d requested", t.name, inserted, t.count)
+	}
+	return inserted, rate
+}
 
-	for i := start; i < end; i += bulkSize {
-		batchEnd := i + bulkSize
-		if batchEnd > end {
-			batchEnd = end
+// insertRows inserts the units [start, end) with the -method and returns the
+// rows affected. The first skip rows were inserted before a resume: they are
+// generated to advance the streams, but not inserted again. A batch that
+// still fails after retries is fatal: carrying on would leave the table short
+// of the requested count.
+func insertRows(db *sql.DB, t table, workerID, start, end int, skip int64) int64 {
+	write := newBatchWriter(method, t, workerID)
+	var inserted int64
+	committed := skip
+
+	generate(t, workerID, start, end, skip, func(rows [][]interface{}) {
+		cp := checkpoint{rows: committed + int64(len(rows))}
+		n, err := execBatch(db, "seed "+t.name, func(tx *sql.Tx) (sql.Result, error) {
+			res, err := write(tx, rows)
+			if err != nil {
+				return nil, err
+			}
+			return res, saveCheckpoint(tx, t.name, workerID, cp)
+		})
+		if err != nil {
+			log.Fatalf("Worker %d: Error inserting %s: %v", workerID, t.name, err)
 		}
+		committed = cp.rows
+		inserted += n
+		atomic.AddInt64(&totalInserted, n)
+	})
 
-		values := make([]string, 0, batchEnd-i)
-		args := make([]interface{}, 0, (batchEnd-i)*3)
-		count := 0
-
-		for j := i; j < batchEnd; j++ {
-			bookID := rand.Intn(numBooks) + 1
-			tagID := rand.Intn(numTags) + 1
-			key := fmt.Sprintf("%d-%d", bookID, tagID)
+	if err := saveCheckpoint(db, t.name, workerID, checkpoint{rows: committed, done: true}); err != nil {
+		log.Fatalf("Worker %d: Error saving checkpoint of %s: %v", workerID, t.name, err)
+	}
+	return inserted
+}
 
-			if inserted[key] {
-				continue
+// generate passes the rows of the units [start, end) of one worker to batch,
+// t.batchSize rows at a time. The first skip rows are generated to advance the
+// streams but not passed on. The rows slice is reused after batch returns.
+func generate(t table, workerID, start, end int, skip int64, batch func(rows [][]interface{})) {
+	rng := datagen.NewStream(seed, t.base, workerID)
+	// Extra columns have a stream of their own so the base columns match
+	// the base table
+	extraRng := datagen.NewStream(seed, t.name+"/extra", workerID)
+
+	rows := make([][]interface{}, 0, t.batchSize)
+	var generated int64
+	for u := start; u < end; u++ {
+		t.gen(rng, u, func(row []interface{}) {
+			if len(t.extra) > 0 {
+				row = append(row, t.extraRow(extraRng)...)
 			}
-			inserted[key] = true
-
-			values = append(values, "(?, ?, ?)")
-			createdAt := baseTime.Add(time.Duration(rand.Intn(5*365*24)) * time.Hour)
-			args = append(args, bookID, tagID, createdAt)
-			count++
-		}
-
-		if len(values) > 0 {
-			query := "INSERT IGNORE INTO book_tags (book_id, tag_id, created_at) VALUES " + strings.Join(values, ",")
-			if _, err := db.Exec(query, args...); err != nil {
-				log.Printf("Worker %d: Error inserting book_tags: %v", workerID, err)
+			if generated++; generated <= skip {
+				return
 			}
-		}
-
-		atomic.AddInt64(&totalInserted, int64(batchEnd-i))
-
-		// Clear map periodically to avoid memory issues
-		if len(inserted) > 100000 {
-			inserted = make(map[string]bool)
-		}
+			rows = append(rows, row)
+			if len(rows) == t.batchSize {
+				batch(rows)
+				rows = rows[:0]
+			}
+		})
+	}
+	if len(rows) > 0 {
+		batch(rows)
 	}
 }
 
-func seedAuthorTags(db *sql.DB, count, numAuthors, numTags int) {
-	log.Printf("Seeding %d author_tags...", count)
-
-	baseTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
-	inserted := make(map[string]bool)
-
-	for i := 0; i < count; i += bulkSize {
-		end := i + bulkSize
-		if end > count {
-			end = count
+// execBatch runs one batch in a transaction, retrying deadlocks and lock
+// wait timeouts like the API's write transactions, and returns the rows
+// affected.
+func execBatch(conn *sql.DB, op string, exec func(tx *sql.Tx) (sql.Result, error)) (int64, error) {
+	var n int64
+	err := db.Retry(context.Background(), op, func() error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
 		}
-
-		values := make([]string, 0, end-i)
-		args := make([]interface{}, 0, (end-i)*3)
-
-		for j := i; j < end; j++ {
-			authorID := rand.Intn(numAuthors) + 1
-			tagID := rand.Intn(numTags) + 1
-			key := fmt.Sprintf("%d-%d", authorID, tagID)
-
-			if inserted[key] {
-				continue
-			}
-			inserted[key] = true
-
-			values = append(values, "(?, ?, ?)")
-			createdAt := baseTime.Add(time.Duration(rand.Intn(5*365*24)) * time.Hour)
-			args = append(args, authorID, tagID, createdAt)
+		res, err := exec(tx)
+		if err == nil {
+			n, err = res.RowsAffected()
 		}
-
-		if len(values) > 0 {
-			query := "INSERT IGNORE INTO author_tags (author_id, tag_id, created_at) VALUES " + strings.Join(values, ",")
-			if _, err := db.Exec(query, args...); err != nil {
-				log.Printf("Error inserting author_tags: %v", err)
-			}
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
-
-		atomic.AddInt64(&totalInserted, int64(end-i))
-	}
+		return tx.Commit()
+	})
+	return n, err
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// nonEmpty returns the tables among ts that already have rows. Rows are
+// inserted with explicit ids, so seeding on top of them would collide.
+func nonEmpty(db *sql.DB, ts []table) []string {
+	var tables []string
+	for _, t := range ts {
+		if t.count == 0 {
+			continue
+		}
+		var one int
+		err := db.QueryRow("SELECT 1 FROM " + t.name + " LIMIT 1").Scan(&one)
+		if err == nil {
+			tables = append(tables, t.name)
+		} else if err != sql.ErrNoRows {
+			log.Fatalf("Failed to check %s: %v", t.name, err)
+		}
 	}
-	return defaultValue
+	return tables
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// Manifest records how a dataset was generated. Running cmd/seed with
+// -scenario set to the manifest against empty tables generates the same
+// rows again.
+type Manifest struct {
+	Seed int64 `json:"seed"`
+	// Flags are the flags given on the command line
+	Flags    map[string]string `json:"flags"`
+	Scenario Scenario          `json:"scenario"`
+	// Fingerprint is stored with the checkpoints; -resume requires the
+	// same one
+	Fingerprint string          `json:"fingerprint"`
+	StartedAt   time.Time       `json:"started_at"`
+	Duration    string          `json:"duration,omitempty"`
+	Tables      []TableManifest `json:"tables"`
+}
+
+type TableManifest struct {
+	Table     string `json:"table"`
+	Requested int    `json:"requested"`
+	// Inserted is the sum of the rows affected by the INSERTs and Rows is
+	// COUNT(*) after seeding; both equal Requested on a complete run.
+	Inserted int64 `json:"inserted"`
+	Rows     int64 `json:"rows"`
+	// RowsPerSec is the load rate of this run with the -method in Flags
+	RowsPerSec float64 `json:"rows_per_sec"`
+}
+
+// newManifest returns the manifest of a run before the rows are counted.
+// It is written when seeding starts, so an interrupted run can be resumed
+// from it.
+func newManifest(sc Scenario, started time.Time) Manifest {
+	m := Manifest{
+		Seed:        sc.Seed,
+		Flags:       make(map[string]string),
+		Scenario:    sc,
+		Fingerprint: runFingerprint,
+		StartedAt:   started,
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "password" {
+			m.Flags[f.Name] = f.Value.String()
+		}
+	})
+	return m
+}
+
+// finish records the duration and the rows of each table.
+func (m *Manifest) finish(db *sql.DB, tables []table, inserted map[string]int64, rates map[string]float64, elapsed time.Duration) {
+	m.Duration = elapsed.Round(time.Millisecond).String()
+	for _, t := range tables {
+		if t.count == 0 {
+			continue
+		}
+		tm := TableManifest{Table: t.name, Requested: t.count, Inserted: inserted[t.name], RowsPerSec: math.Round(rates[t.name])}
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + t.name).Scan(&tm.Rows); err != nil {
+			log.Printf("Warning: failed to count %s: %v", t.name, err)
+			tm.Rows = -1
+		}
+		m.Tables = append(m.Tables, tm)
+	}
+}
+
+func (m Manifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+const dateLayout = "2006-01-02"
+
+// Scenario describes a dataset and how to load it. It is read from a JSON
+// file with -scenario, and flags given on the command line override it. The
+// manifest records the resolved scenario, so a manifest works as a scenario
+// too.
+type Scenario struct {
+	Description string `json:"description,omitempty"`
+	// Seed 0 means defaultSeed
+	Seed   int64  `json:"seed,omitempty"`
+	Method string `json:"method,omitempty"`
+	// Targets is a -table spec; empty means the base tables
+	Targets string                    `json:"targets,omitempty"`
+	Tables  map[string]*TableScenario `json:"tables"`
+}
+
+// TableScenario sets up one table. Base tables missing from a scenario file
+// get no rows. A variant is seeded with the rows of its base, so its entry
+// may only set Dists for its extra columns.
+type TableScenario struct {
+	Count int `json:"count,omitempty"`
+	// From and To bound created_at as YYYY-MM-DD, To exclusive. The default
+	// is datagen.DefaultWindow, and for book_events the 90 days up to
+	// datagen.DefaultEventEnd.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Dists maps a column to a distribution spec as in -dist
+	Dists     map[string]string `json:"dists,omitempty"`
+	BatchSize int               `json:"batch_size,omitempty"`
+	Workers   int               `json:"workers,omitempty"`
+}
+
+// parallelTables get defaultWorkers workers; the small tables get one.
+var parallelTables = map[string]bool{"books": true, "book_tags": true, "book_events": true}
+
+// defaultScenario is the dataset seeded without -scenario.
+func defaultScenario() Scenario {
+	return Scenario{Seed: defaultSeed, Tables: map[string]*TableScenario{
+		"authors":     {Count: defaultAuthors},
+		"tags":        {Count: defaultTags},
+		"books":       {Count: defaultBooks},
+		"book_tags":   {Count: defaultBookTags},
+		"author_tags": {Count: defaultAuthorTags},
+		"book_events": {},
+	}}
+}
+
+// loadScenario reads a scenario file, or the scenario of a manifest.
+func loadScenario(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	var manifest struct {
+		Scenario *Scenario `json:"scenario"`
+	}
+	if json.Unmarshal(data, &manifest) == nil && manifest.Scenario != nil {
+		return *manifest.Scenario, nil
+	}
+
+	var s Scenario
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return Scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Tables == nil {
+		s.Tables = make(map[string]*TableScenario)
+	}
+	return s, nil
+}
+
+// table returns the entry of name, adding an empty one if needed.
+func (s *Scenario) table(name string) *TableScenario {
+	t, ok := s.Tables[name]
+	if !ok {
+		t = &TableScenario{}
+		s.Tables[name] = t
+	}
+	return t
+}
+
+// countFlags are the flags that set a table's count.
+var countFlags = map[string]string{
+	"authors": "authors", "tags": "tags", "books": "books",
+	"book-tags": "book_tags", "author-tags": "author_tags", "events": "book_events",
+}
+
+// override applies the flags given on the command line.
+func (s *Scenario) override(dists datagen.Dists, zone *time.Location) error {
+	eventDays := 0
+	flag.Visit(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		value := getter.Get()
+		if table, ok := countFlags[f.Name]; ok {
+			s.table(table).Count = value.(int)
+			return
+		}
+		switch f.Name {
+		case "seed":
+			s.Seed = value.(int64)
+		case "method":
+			s.Method = value.(string)
+		case "table":
+			s.Targets = value.(string)
+		case "event-end":
+			s.table("book_events").To = value.(string)
+		case "event-days":
+			eventDays = value.(int)
+		case "batch-size":
+			for _, name := range generated {
+				s.table(name).BatchSize = value.(int)
+			}
+		case "workers":
+			for _, name := range generated {
+				s.table(name).Workers = value.(int)
+			}
+		}
+	})
+
+	for key, d := range dists {
+		table, column, _ := strings.Cut(key, ".")
+		t := s.table(table)
+		if t.Dists == nil {
+			t.Dists = make(map[string]string)
+		}
+		t.Dists[column] = d.String()
+	}
+
+	if eventDays != 0 {
+		if eventDays < 0 {
+			return fmt.Errorf("-event-days must be positive")
+		}
+		ev := s.table("book_events")
+		to, err := eventEnd(ev.To, zone)
+		if err != nil {
+			return err
+		}
+		ev.To = to.Format(dateLayout)
+		ev.From = to.AddDate(0, 0, -eventDays).Format(dateLayout)
+	}
+	return nil
+}
+
+// eventEnd parses the end of the book_events window; empty means
+// datagen.DefaultEventEnd. The default does not depend on the day of the
+// run, so the same flags always generate the same events.
+func eventEnd(to string, zone *time.Location) (time.Time, error) {
+	if to == "" {
+		return datagen.DefaultEventEnd(zone), nil
+	}
+	end, err := time.ParseInLocation(dateLayout, to, zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("book_events: invalid to: %w", err)
+	}
+	return end, nil
+}
+
+// resolve fills in the defaults, so the manifest records every setting,
+// and checks the scenario.
+func (s *Scenario) resolve(zone *time.Location) error {
+	if s.Seed == 0 {
+		s.Seed = defaultSeed
+	}
+	if s.Method == "" {
+		s.Method = methodInsert
+	}
+	if s.Method != methodInsert && s.Method != methodLoadData {
+		return fmt.Errorf("unknown method %q: use %s or %s", s.Method, methodInsert, methodLoadData)
+	}
+
+	for name, t := range s.Tables {
+		if indexOf(generated, name) >= 0 {
+			continue
+		}
+		v, ok := variant.Default.Get(name)
+		if !ok {
+			return fmt.Errorf("unknown table %s", name)
+		}
+		if t.Count != 0 || t.From != "" || t.To != "" || t.BatchSize != 0 || t.Workers != 0 {
+			return fmt.Errorf("%s is seeded with the rows of %s; it can only set dists", name, v.Base)
+		}
+	}
+
+	for _, name := range generated {
+		t := s.table(name)
+		if t.Count < 0 {
+			return fmt.Errorf("%s: negative count", name)
+		}
+		if t.BatchSize == 0 {
+			t.BatchSize = defaultBatchSize
+		}
+		if t.Workers == 0 {
+			t.Workers = 1
+			if parallelTables[name] {
+				t.Workers = defaultWorkers
+			}
+		}
+		if t.BatchSize < 1 || t.Workers < 1 {
+			return fmt.Errorf("%s: batch_size and workers must be positive", name)
+		}
+		// tags have no created_at
+		if name == "tags" {
+			continue
+		}
+		if err := t.resolveWindow(name, zone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TableScenario) resolveWindow(name string, zone *time.Location) error {
+	var from, to time.Time
+	var err error
+	if name == "book_events" {
+		if to, err = eventEnd(t.To, zone); err != nil {
+			return err
+		}
+		from = to.AddDate(0, 0, -defaultEventDays)
+	} else {
+		w := datagen.DefaultWindow(zone)
+		from, to = w.From, w.To
+		if t.To != "" {
+			if to, err = time.ParseInLocation(dateLayout, t.To, zone); err != nil {
+				return fmt.Errorf("%s: invalid to: %w", name, err)
+			}
+		}
+	}
+	if t.From != "" {
+		if from, err = time.ParseInLocation(dateLayout, t.From, zone); err != nil {
+			return fmt.Errorf("%s: invalid from: %w", name, err)
+		}
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("%s: from %s is not before to %s", name, from.Format(dateLayout), to.Format(dateLayout))
+	}
+	t.From, t.To = from.Format(dateLayout), to.Format(dateLayout)
+	return nil
+}
+
+// dataset returns the generator settings of a resolved scenario.
+func (s Scenario) dataset(zone *time.Location) (datagen.Dataset, error) {
+	ds := datagen.Dataset{
+		Authors: s.Tables["authors"].Count,
+		Books:   s.Tables["books"].Count,
+		Tags:    s.Tables["tags"].Count,
+		Dists:   datagen.Dists{},
+		Zone:    zone,
+		Windows: make(map[string]datagen.Window),
+	}
+
+	names := make([]string, 0, len(s.Tables))
+	for name := range s.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := s.Tables[name]
+		for column, spec := range t.Dists {
+			if err := ds.Dists.Set(name + "." + column + "=" + spec); err != nil {
+				return ds, err
+			}
+		}
+		if t.From == "" {
+			continue
+		}
+		from, _ := time.ParseInLocation(dateLayout, t.From, zone)
+		to, _ := time.ParseInLocation(dateLayout, t.To, zone)
+		if name == "book_events" {
+			ds.EventEnd = to
+			ds.EventDays = int(to.Sub(from).Round(24*time.Hour) / (24 * time.Hour))
+		} else {
+			ds.Windows[name] = datagen.Window{From: from, To: to}
+		}
+	}
+	return ds, nil
+}
+
+// maxWorkers is the largest number of workers of a table.
+func (s Scenario) maxWorkers() int {
+	n := 1
+	for _, t := range s.Tables {
+		if t.Workers > n {
+			n = t.Workers
+		}
+	}
+	return n
+}
+
+// fingerprint identifies the generated data of a resolved scenario.
+// Checkpoints are only valid for a run with the same fingerprint. The
+// batch size, method and targets do not change the rows.
+func (s Scenario) fingerprint() string {
+	tables := make(map[string]TableScenario, len(s.Tables))
+	for name, t := range s.Tables {
+		c := *t
+		c.BatchSize = 0
+		tables[name] = c
+	}
+	data, err := json.Marshal(struct {
+		Seed   int64
+		Tables map[string]TableScenario
+	}{s.Seed, tables})
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
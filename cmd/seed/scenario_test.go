@@ -0,0 +1,176 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShippedScenarios(t *testing.T) {
+	paths, err := filepath.Glob("../../scenarios/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios: %v", err)
+	}
+	for _, path := range paths {
+		sc, err := loadScenario(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sc.resolve(time.UTC); err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if _, err := resolveTargets(sc.Targets); err != nil {
+			t.Errorf("%s: targets: %v", path, err)
+		}
+		ds, err := sc.dataset(time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if sc.Tables["book_tags"].Count > ds.Books*ds.Tags {
+			t.Errorf("%s: more book_tags than pairs", path)
+		}
+	}
+}
+
+func TestManifestAsScenario(t *testing.T) {
+	sc := defaultScenario()
+	sc.Tables["books"].Dists = map[string]string{"author_id": "zipf:1.1"}
+	if err := sc.resolve(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := newManifest(sc, time.Now()).write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.resolve(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.fingerprint() != sc.fingerprint() {
+		t.Error("the scenario of a manifest differs from the run's")
+	}
+}
+
+func TestDefaultScenario(t *testing.T) {
+	sc := defaultScenario()
+	if err := sc.resolve(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Seed != 1 || sc.Method != methodInsert {
+		t.Errorf("seed %d, method %s", sc.Seed, sc.Method)
+	}
+	if w := sc.Tables["books"].Workers; w != defaultWorkers {
+		t.Errorf("books workers = %d", w)
+	}
+	if w := sc.Tables["authors"].Workers; w != 1 {
+		t.Errorf("authors workers = %d", w)
+	}
+	books := sc.Tables["books"]
+	if books.From != "2020-01-01" || books.To != "2024-12-30" {
+		t.Errorf("books window %s..%s", books.From, books.To)
+	}
+	ev := sc.Tables["book_events"]
+	from, _ := time.Parse(dateLayout, ev.From)
+	to, _ := time.Parse(dateLayout, ev.To)
+	if to.Sub(from) != defaultEventDays*24*time.Hour || ev.To != "2024-12-30" {
+		t.Errorf("book_events window %s..%s", ev.From, ev.To)
+	}
+
+	ds, err := sc.dataset(time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.EventDays != defaultEventDays || !ds.EventEnd.Equal(to) {
+		t.Errorf("events: %d days to %v", ds.EventDays, ds.EventEnd)
+	}
+}
+
+func TestScenarioFingerprint(t *testing.T) {
+	resolved := func(edit func(*Scenario)) Scenario {
+		sc := defaultScenario()
+		sc.table("book_events").To = "2024-07-01"
+		edit(&sc)
+		if err := sc.resolve(time.UTC); err != nil {
+			t.Fatal(err)
+		}
+		return sc
+	}
+	base := resolved(func(*Scenario) {}).fingerprint()
+
+	same := resolved(func(sc *Scenario) {
+		sc.Method = methodLoadData
+		sc.Targets = "hash"
+		sc.Tables["books"].BatchSize = 100
+	})
+	if same.fingerprint() != base {
+		t.Error("batch size, method or targets changed the fingerprint")
+	}
+
+	for name, edit := range map[string]func(*Scenario){
+		"count":   func(sc *Scenario) { sc.Tables["books"].Count++ },
+		"workers": func(sc *Scenario) { sc.Tables["books"].Workers = 4 },
+		"window":  func(sc *Scenario) { sc.Tables["books"].From = "2021-01-01" },
+		"dists":   func(sc *Scenario) { sc.Tables["books"].Dists = map[string]string{"author_id": "zipf:1.1"} },
+		"seed":    func(sc *Scenario) { sc.Seed = 2 },
+	} {
+		if resolved(edit).fingerprint() == base {
+			t.Errorf("changing the %s kept the fingerprint", name)
+		}
+	}
+}
+
+func TestScenarioErrors(t *testing.T) {
+	for name, edit := range map[string]func(*Scenario){
+		"unknown table": func(sc *Scenario) { sc.table("nope").Count = 1 },
+		"variant count": func(sc *Scenario) { sc.table("books_hash").Count = 1 },
+		"method":        func(sc *Scenario) { sc.Method = "copy" },
+		"window":        func(sc *Scenario) { sc.Tables["books"].From = "2030-01-01" },
+		"date":          func(sc *Scenario) { sc.Tables["books"].To = "2024/01/01" },
+		"workers":       func(sc *Scenario) { sc.Tables["books"].Workers = -1 },
+	} {
+		sc := defaultScenario()
+		edit(&sc)
+		if err := sc.resolve(time.UTC); err == nil {
+			t.Errorf("%s: resolve succeeded", name)
+		}
+	}
+}
+
+// TestResolveReferences checks that a scenario seeding a table without the
+// tables it references is an error, not a panic, when there is no database
+// to read their ids from.
+func TestResolveReferences(t *testing.T) {
+	for name, c := range map[string]struct {
+		counts map[string]int
+		ok     bool
+	}{
+		"books only":       {map[string]int{"books": 10}, false},
+		"books":            {map[string]int{"authors": 5, "books": 10}, true},
+		"book_tags":        {map[string]int{"books": 10, "book_tags": 5}, false},
+		"author_tags":      {map[string]int{"authors": 5, "tags": 3, "author_tags": 5}, true},
+		"events only":      {map[string]int{"book_events": 10}, false},
+		"nothing to seed":  {map[string]int{}, true},
+		"events and books": {map[string]int{"authors": 1, "books": 10, "book_events": 10}, true},
+	} {
+		sc := Scenario{Seed: 1, Tables: map[string]*TableScenario{}}
+		for table, n := range c.counts {
+			sc.table(table).Count = n
+		}
+		if err := sc.resolve(time.UTC); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		ds, err := sc.dataset(time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := resolveReferences(nil, sc, &ds); (err == nil) != c.ok {
+			t.Errorf("%s: resolveReferences = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/datagen"
+	"github.com/sters/try-mysql-partitioning/sqlscript"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+// generated are the tables the seeder has generators for, in the order they
+// are seeded. A variant is seeded with the rows of its base.
+var generated = []string{"authors", "tags", "books", "book_tags", "author_tags", "book_events"}
+
+// resolveTargets expands -table: a comma-separated list of tables, variant
+// groups, or "all" for every base table and variant. Empty means the base
+// tables.
+func resolveTargets(spec string) ([]variant.Variant, error) {
+	if spec == "" {
+		spec = strings.Join(generated, ",")
+	}
+
+	var targets []variant.Variant
+	seen := make(map[string]bool)
+	add := func(v variant.Variant) error {
+		if indexOf(generated, v.Base) < 0 {
+			return fmt.Errorf("%s: no generator for %s", v.Table, v.Base)
+		}
+		if !seen[v.Table] {
+			seen[v.Table] = true
+			targets = append(targets, v)
+		}
+		return nil
+	}
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		var vs []variant.Variant
+		switch {
+		case name == "all":
+			for _, table := range generated {
+				vs = append(vs, mustGet(table))
+			}
+			for _, group := range variant.Default.Groups() {
+				vs = append(vs, variant.Default.Group(group)...)
+			}
+		case variant.Default.HasGroup(name):
+			vs = variant.Default.Group(name)
+		default:
+			v, err := variant.Default.Lookup(name)
+			if err != nil {
+				return nil, err
+			}
+			vs = []variant.Variant{v}
+		}
+		for _, v := range vs {
+			if err := add(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Referenced tables first, so a partial run leaves consistent data
+	sort.SliceStable(targets, func(i, j int) bool {
+		return indexOf(generated, targets[i].Base) < indexOf(generated, targets[j].Base)
+	})
+	return targets, nil
+}
+
+// targetTables returns what to seed for each target: the generator of its
+// base under the variant's name, plus generators for its extra columns.
+func targetTables(targets []variant.Variant, bases []table, ds datagen.Dataset) ([]table, error) {
+	var tables []table
+	for _, v := range targets {
+		t := bases[indexOf(generated, v.Base)]
+		t.name = v.Table
+		if len(v.Extra) > 0 {
+			t.columns = append(append([]string(nil), t.columns...), v.Extra...)
+			for _, column := range v.Extra {
+				gen, err := ds.Extra(v.Table, column)
+				if err != nil {
+					return nil, err
+				}
+				t.extra = append(t.extra, gen)
+			}
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// missingTables returns the tables among ts that do not exist.
+func missingTables(db *sql.DB, ts []table) []string {
+	var missing []string
+	for _, t := range ts {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", t.name).Scan(&n)
+		if err != nil {
+			log.Fatalf("Failed to check %s: %v", t.name, err)
+		}
+		if n == 0 {
+			missing = append(missing, t.name)
+		}
+	}
+	return missing
+}
+
+// schemaKeywords are the statements of a setup script that make tables. The
+// INSERT ... SELECT copies from the base tables and the SELECTs that show
+// the result are skipped.
+var schemaKeywords = map[string]bool{"CREATE": true, "DROP": true, "ALTER": true, "CALL": true}
+
+// createVariants runs the schema part of the setup scripts of targets. A
+// script creates every table of its group, so tables in the same group that
+// are not targeted are recreated empty.
+func createVariants(db *sql.DB, targets []variant.Variant) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	runner := &sqlscript.Runner{
+		Conn:        conn,
+		StopOnError: true,
+		OnResult: func(res sqlscript.Result) {
+			log.Printf("  %s %10v  %s", res.Pos(), res.Duration.Round(time.Millisecond), res.Summary(80))
+		},
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range targets {
+		if v.Setup == "" || seen[v.Setup] {
+			continue
+		}
+		seen[v.Setup] = true
+
+		stmts, err := sqlscript.ParseFile(v.Setup)
+		if err != nil {
+			log.Fatalf("Failed to parse: %v", err)
+		}
+		var schema []sqlscript.Statement
+		for _, s := range stmts {
+			if schemaKeywords[s.Keyword()] {
+				schema = append(schema, s)
+			}
+		}
+
+		log.Printf("Creating %s tables from %s (%d of %d statements)", v.Group, v.Setup, len(schema), len(stmts))
+		if _, err := runner.Run(ctx, schema); err != nil {
+			log.Fatalf("Failed: %v", err)
+		}
+	}
+}
+
+// extraRow draws the values of t's extra columns.
+func (t table) extraRow(rng *rand.Rand) []interface{} {
+	values := make([]interface{}, len(t.extra))
+	for i, gen := range t.extra {
+		values[i] = gen(rng)
+	}
+	return values
+}
+
+func mustGet(table string) variant.Variant {
+	v, ok := variant.Default.Get(table)
+	if !ok {
+		panic("variant: " + table + " is not registered")
+	}
+	return v
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
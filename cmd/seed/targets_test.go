@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sters/try-mysql-partitioning/datagen"
+)
+
+func tableNames(t *testing.T, spec string) []string {
+	t.Helper()
+	targets, err := resolveTargets(spec)
+	if err != nil {
+		t.Fatalf("resolveTargets(%q): %v", spec, err)
+	}
+	names := make([]string, len(targets))
+	for i, v := range targets {
+		names[i] = v.Table
+	}
+	return names
+}
+
+func TestResolveTargets(t *testing.T) {
+	if got := tableNames(t, ""); !reflect.DeepEqual(got, generated) {
+		t.Errorf("default = %v", got)
+	}
+
+	// Ordered by base, duplicates dropped
+	got := tableNames(t, "book_tags_hash, hash, books")
+	want := []string{"authors_hash", "books_hash", "books", "book_tags_hash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	all := tableNames(t, "all")
+	if all[0] != "authors" || len(all) < 30 {
+		t.Errorf("all = %v", all)
+	}
+
+	for _, spec := range []string{"nope", "outbox", "books,"} {
+		if _, err := resolveTargets(spec); err == nil {
+			t.Errorf("resolveTargets(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestTargetTablesExtraColumns(t *testing.T) {
+	targets, err := resolveTargets("books_list,author_tags_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bases := make([]table, len(generated))
+	for i, name := range generated {
+		bases[i] = table{name: name, base: name, columns: []string{"c"}}
+	}
+
+	tables, err := targetTables(targets, bases, datagen.Dataset{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables[0].name != "books_list" || tables[0].base != "books" ||
+		!reflect.DeepEqual(tables[0].columns, []string{"c", "status"}) || len(tables[0].extra) != 1 {
+		t.Errorf("books_list = %+v", tables[0])
+	}
+	if !reflect.DeepEqual(tables[1].columns, []string{"c", "region"}) {
+		t.Errorf("author_tags_list columns = %v", tables[1].columns)
+	}
+	if !reflect.DeepEqual(bases[2].columns, []string{"c"}) {
+		t.Errorf("base columns modified: %v", bases[2].columns)
+	}
+}
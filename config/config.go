@@ -0,0 +1,248 @@
+// Package config holds the database settings shared by the API server and
+// the cmd tools. Settings are resolved from, in order of precedence,
+// command-line flags, DB_* environment variables, an optional JSON config
+// file and built-in defaults.
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DB describes how to connect to MySQL and size the connection pool.
+type DB struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	Socket   string // Unix socket path; overrides Host and Port when set
+
+	// TLS is "false", "true", "skip-verify" or "preferred". Setting TLSCA
+	// (and optionally TLSCert/TLSKey for client auth) turns TLS on with a
+	// custom root CA.
+	TLS     string
+	TLSCA   string
+	TLSCert string
+	TLSKey  string
+
+	TimeZone       string // Location for DATETIME values, e.g. "Local" or "Asia/Tokyo"
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// Replicas are read replicas, each either host[:port] sharing the
+	// settings above or a DSN with its own user, TLS and other parameters.
+	// Reads skip a replica more than MaxReplicaLag behind its source.
+	Replicas      []string
+	MaxReplicaLag time.Duration
+
+	// Per-binary driver options, not exposed as flags.
+	MaxAllowedPacket int
+	MultiStatements  bool
+}
+
+// Default returns the settings used when nothing else is configured, which
+// match the docker-compose setup.
+func Default() DB {
+	return DB{
+		Host:            "localhost",
+		Port:            "3306",
+		User:            "app",
+		Password:        "app",
+		Name:            "bookdb",
+		TLS:             "false",
+		TimeZone:        "Local",
+		ConnectTimeout:  10 * time.Second,
+		MaxOpenConns:    100,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: time.Hour,
+		MaxReplicaLag:   10 * time.Second,
+	}
+}
+
+// MySQL builds the driver configuration.
+func (c DB) MySQL() (*mysql.Config, error) {
+	mc := mysql.NewConfig()
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.DBName = c.Name
+	mc.ParseTime = true
+	mc.Timeout = c.ConnectTimeout
+	mc.ReadTimeout = c.ReadTimeout
+	mc.WriteTimeout = c.WriteTimeout
+	mc.MultiStatements = c.MultiStatements
+	if c.MaxAllowedPacket > 0 {
+		mc.MaxAllowedPacket = c.MaxAllowedPacket
+	}
+
+	if c.Socket != "" {
+		mc.Net = "unix"
+		mc.Addr = c.Socket
+	} else {
+		mc.Net = "tcp"
+		mc.Addr = net.JoinHostPort(c.Host, c.Port)
+	}
+
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("time zone: %w", err)
+	}
+	mc.Loc = loc
+
+	if c.TLSCA != "" {
+		name, err := c.registerTLS()
+		if err != nil {
+			return nil, err
+		}
+		mc.TLSConfig = name
+	} else {
+		switch c.TLS {
+		case "", "false":
+		case "true", "skip-verify", "preferred":
+			mc.TLSConfig = c.TLS
+		default:
+			return nil, fmt.Errorf("tls: unknown mode %q", c.TLS)
+		}
+	}
+
+	return mc, nil
+}
+
+// DSN returns the connection string for these settings.
+func (c DB) DSN() (string, error) {
+	mc, err := c.MySQL()
+	if err != nil {
+		return "", err
+	}
+	return mc.FormatDSN(), nil
+}
+
+// Open returns a pool with the configured limits. It does not connect;
+// callers Ping when they need to.
+func (c DB) Open() (*sql.DB, error) {
+	mc, err := c.MySQL()
+	if err != nil {
+		return nil, err
+	}
+	return c.open(mc)
+}
+
+// OpenReplica returns a pool for one entry of Replicas, with c's limits.
+func (c DB) OpenReplica(spec string) (*sql.DB, error) {
+	mc, err := c.ReplicaMySQL(spec)
+	if err != nil {
+		return nil, err
+	}
+	return c.open(mc)
+}
+
+func (c DB) open(mc *mysql.Config) (*sql.DB, error) {
+	connector, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := sql.OpenDB(connector)
+	c.ApplyPool(pool)
+	return pool, nil
+}
+
+// ReplicaMySQL builds the driver configuration of one entry of Replicas. A
+// DSN always parses times, and without loc= uses c's time zone so that
+// replicas and the primary read the same DATETIME values.
+func (c DB) ReplicaMySQL(spec string) (*mysql.Config, error) {
+	// A DSN has at least the slash before the database name
+	if !strings.ContainsAny(spec, "@/") {
+		rc, err := c.WithAddr(spec)
+		if err != nil {
+			return nil, err
+		}
+		return rc.MySQL()
+	}
+
+	mc, err := mysql.ParseDSN(spec)
+	if err != nil {
+		return nil, fmt.Errorf("replica DSN: %w", err)
+	}
+	mc.ParseTime = true
+	if !strings.Contains(spec, "loc=") {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("time zone: %w", err)
+		}
+		mc.Loc = loc
+	}
+	return mc, nil
+}
+
+// ApplyPool sets the pool limits on an already opened pool.
+func (c DB) ApplyPool(pool *sql.DB) {
+	pool.SetMaxOpenConns(c.MaxOpenConns)
+	pool.SetMaxIdleConns(c.MaxIdleConns)
+	pool.SetConnMaxLifetime(c.ConnMaxLifetime)
+}
+
+// WithName returns a copy of c that connects to another schema on the same
+// server.
+func (c DB) WithName(name string) DB {
+	c.Name = name
+	return c
+}
+
+// WithAddr returns a copy of c that connects to another server given as
+// host or host:port, e.g. a read replica. The port defaults to c's.
+func (c DB) WithAddr(addr string) (DB, error) {
+	host, port := addr, c.Port
+	if strings.Contains(addr, ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(addr); err != nil {
+			return DB{}, fmt.Errorf("address %q: %w", addr, err)
+		}
+	}
+	if host == "" {
+		return DB{}, fmt.Errorf("address %q: no host", addr)
+	}
+	c.Host, c.Port, c.Socket = host, port, ""
+	return c, nil
+}
+
+// registerTLS registers a custom TLS config with the driver so that it can
+// be referred to from a DSN string, and returns its name.
+func (c DB) registerTLS() (string, error) {
+	pem, err := os.ReadFile(c.TLSCA)
+	if err != nil {
+		return "", fmt.Errorf("tls ca: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return "", fmt.Errorf("tls ca: no certificates in %s", c.TLSCA)
+	}
+
+	tc := &tls.Config{RootCAs: roots}
+	if c.TLSCert != "" || c.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return "", fmt.Errorf("tls client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	const name = "config"
+	if err := mysql.RegisterTLSConfig(name, tc); err != nil {
+		return "", err
+	}
+	return name, nil
+}
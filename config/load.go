@@ -0,0 +1,253 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting ties one DB field to its flag name, which is also its key in the
+// config file, and its environment variable.
+type setting struct {
+	name  string
+	env   string
+	usage string
+	set   func(c *DB, s string) error
+}
+
+var settings = []setting{
+	{"host", "DB_HOST", "Database host", stringField(func(c *DB) *string { return &c.Host })},
+	{"port", "DB_PORT", "Database port", stringField(func(c *DB) *string { return &c.Port })},
+	{"user", "DB_USER", "Database user", stringField(func(c *DB) *string { return &c.User })},
+	{"password", "DB_PASSWORD", "Database password", stringField(func(c *DB) *string { return &c.Password })},
+	{"db", "DB_NAME", "Database name", stringField(func(c *DB) *string { return &c.Name })},
+	{"socket", "DB_SOCKET", "Unix socket path (overrides host and port)", stringField(func(c *DB) *string { return &c.Socket })},
+	{"tls", "DB_TLS", "TLS mode: false, true, skip-verify or preferred", stringField(func(c *DB) *string { return &c.TLS })},
+	{"tls-ca", "DB_TLS_CA", "PEM file with the CA to verify the server against", stringField(func(c *DB) *string { return &c.TLSCA })},
+	{"tls-cert", "DB_TLS_CERT", "PEM client certificate", stringField(func(c *DB) *string { return &c.TLSCert })},
+	{"tls-key", "DB_TLS_KEY", "PEM client key", stringField(func(c *DB) *string { return &c.TLSKey })},
+	{"tz", "DB_TZ", "Time zone for DATETIME values (Local, UTC, Asia/Tokyo, ...)", stringField(func(c *DB) *string { return &c.TimeZone })},
+	{"connect-timeout", "DB_CONNECT_TIMEOUT", "Dial timeout", durationField(func(c *DB) *time.Duration { return &c.ConnectTimeout })},
+	{"read-timeout", "DB_READ_TIMEOUT", "I/O read timeout (0 = none)", durationField(func(c *DB) *time.Duration { return &c.ReadTimeout })},
+	{"write-timeout", "DB_WRITE_TIMEOUT", "I/O write timeout (0 = none)", durationField(func(c *DB) *time.Duration { return &c.WriteTimeout })},
+	{"max-open-conns", "DB_MAX_OPEN_CONNS", "Maximum open connections", intField(func(c *DB) *int { return &c.MaxOpenConns })},
+	{"max-idle-conns", "DB_MAX_IDLE_CONNS", "Maximum idle connections", intField(func(c *DB) *int { return &c.MaxIdleConns })},
+	{"conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "Maximum connection lifetime", durationField(func(c *DB) *time.Duration { return &c.ConnMaxLifetime })},
+	{"replicas", "DB_REPLICAS", "Read replicas, comma-separated: host[:port] sharing these settings, or a DSN of its own", listField(func(c *DB) *[]string { return &c.Replicas })},
+	{"replica-max-lag", "DB_REPLICA_MAX_LAG", "Replication lag beyond which a replica gets no reads", durationField(func(c *DB) *time.Duration { return &c.MaxReplicaLag })},
+}
+
+// Loader resolves DB settings for one binary. Register its flags before
+// flag.Parse, then call Load.
+type Loader struct {
+	defaults DB
+	fs       *flag.FlagSet
+	path     *string
+	flags    map[string]string
+}
+
+// RegisterFlags adds the DB flags and -config to fs. defaults are the
+// lowest-precedence values; tools adjust Default() for their own needs
+// (e.g. a smaller pool).
+func RegisterFlags(fs *flag.FlagSet, defaults DB) *Loader {
+	l := &Loader{
+		defaults: defaults,
+		fs:       fs,
+		path:     fs.String("config", os.Getenv("CONFIG_FILE"), "JSON config file with DB settings (keys are the flag names)"),
+		flags:    make(map[string]string),
+	}
+	for _, s := range settings {
+		fs.Var(&rawFlag{name: s.name, into: l.flags, def: currentValue(defaults, s.name)}, s.name, s.usage+" (env "+s.env+")")
+	}
+	return l
+}
+
+// Load applies the config file, environment and explicitly set flags, in
+// that order, on top of the defaults.
+func (l *Loader) Load() (DB, error) {
+	c := l.defaults
+
+	if *l.path != "" {
+		values, err := readFile(*l.path)
+		if err != nil {
+			return DB{}, err
+		}
+		for _, s := range settings {
+			if v, ok := values[s.name]; ok {
+				if err := s.set(&c, v); err != nil {
+					return DB{}, fmt.Errorf("%s: %s: %w", *l.path, s.name, err)
+				}
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(&c, v); err != nil {
+				return DB{}, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var err error
+	l.fs.Visit(func(f *flag.Flag) {
+		v, ok := l.flags[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		for _, s := range settings {
+			if s.name == f.Name {
+				if serr := s.set(&c, v); serr != nil {
+					err = fmt.Errorf("-%s: %w", f.Name, serr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return DB{}, err
+	}
+
+	// Validate early so a typo fails at startup, not on first connect
+	if _, err := c.MySQL(); err != nil {
+		return DB{}, err
+	}
+	for i, spec := range c.Replicas {
+		if _, err := c.ReplicaMySQL(spec); err != nil {
+			return DB{}, fmt.Errorf("replica %d: %w", i, err)
+		}
+	}
+	return c, nil
+}
+
+// MustLoad is Load for main functions.
+func (l *Loader) MustLoad() DB {
+	c, err := l.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(2)
+	}
+	return c
+}
+
+// readFile reads a flat JSON object. Numbers and booleans are accepted as
+// well as strings.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case float64:
+			values[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[k] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: %s: unsupported value %v", path, k, v)
+		}
+	}
+	return values, nil
+}
+
+// rawFlag records the flag's string so Load can apply it last, after the
+// environment and config file.
+type rawFlag struct {
+	name string
+	into map[string]string
+	def  string
+}
+
+func (f *rawFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *rawFlag) Set(s string) error {
+	f.into[f.name] = s
+	return nil
+}
+
+func currentValue(c DB, name string) string {
+	switch name {
+	case "host":
+		return c.Host
+	case "port":
+		return c.Port
+	case "user":
+		return c.User
+	case "db":
+		return c.Name
+	case "tls":
+		return c.TLS
+	case "tz":
+		return c.TimeZone
+	case "connect-timeout":
+		return c.ConnectTimeout.String()
+	case "max-open-conns":
+		return strconv.Itoa(c.MaxOpenConns)
+	case "max-idle-conns":
+		return strconv.Itoa(c.MaxIdleConns)
+	case "conn-max-lifetime":
+		return c.ConnMaxLifetime.String()
+	case "replica-max-lag":
+		return c.MaxReplicaLag.String()
+	}
+	return "" // Don't print secrets or empty paths as defaults
+}
+
+func stringField(field func(*DB) *string) func(*DB, string) error {
+	return func(c *DB, s string) error {
+		*field(c) = s
+		return nil
+	}
+}
+
+// listField splits a comma-separated value, dropping empty entries.
+func listField(field func(*DB) *[]string) func(*DB, string) error {
+	return func(c *DB, s string) error {
+		var list []string
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func intField(field func(*DB) *int) func(*DB, string) error {
+	return func(c *DB, s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func durationField(field func(*DB) *time.Duration) func(*DB, string) error {
+	return func(c *DB, s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
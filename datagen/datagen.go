@@ -0,0 +1,161 @@
+// Package datagen generates the seed dataset. Each worker draws from its own
+// random stream derived from the seed, the table and the worker number, and
+// every row carries an explicit id, so the same settings produce the same
+// rows however the workers are scheduled.
+package datagen
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/events"
+)
+
+// NewStream returns the random stream of one worker of one table.
+func NewStream(seed int64, table string, worker int) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", table, worker)
+	return rand.New(rand.NewSource(int64(splitmix64(uint64(seed) ^ h.Sum64()))))
+}
+
+// splitmix64 scrambles x so that nearby seeds give unrelated streams.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Columns inserted per table, in the order the row functions return them.
+var (
+	AuthorColumns    = []string{"id", "name", "created_at"}
+	TagColumns       = []string{"id", "name"}
+	BookColumns      = []string{"id", "title", "author_id", "created_at"}
+	BookTagColumns   = []string{"book_id", "tag_id", "created_at"}
+	AuthorTagColumns = []string{"author_id", "tag_id", "created_at"}
+	EventColumns     = []string{"id", "book_id", "event_type", "created_at"}
+)
+
+var (
+	tagCategories = []string{"Fiction", "Non-Fiction", "Science", "History", "Art", "Technology",
+		"Philosophy", "Biography", "Travel", "Cooking", "Health", "Business", "Education", "Sports"}
+	titles = []string{"The Art of", "Introduction to", "Advanced", "Complete Guide to",
+		"Mastering", "Understanding", "Practical", "Essential", "Modern", "Classic"}
+	subjects = []string{"Programming", "Design", "Science", "History", "Mathematics",
+		"Physics", "Chemistry", "Biology", "Economics", "Philosophy"}
+)
+
+// Dataset describes the rows to generate. Row functions take the 0-based
+// index of the row within its table; ids are index+1.
+type Dataset struct {
+	Authors int
+	Books   int
+	Tags    int
+
+	// Dists shapes the columns in DistColumns; the rest are uniform.
+	Dists Dists
+
+	// Zone is the location of generated DATETIMEs. Use the connection's
+	// location so the stored wall-clock values do not depend on the machine.
+	Zone *time.Location
+	// Windows holds the created_at window of catalog tables by table name;
+	// tables without one use DefaultWindow.
+	Windows map[string]Window
+	// Events are spread over EventDays days ending at EventEnd.
+	EventEnd  time.Time
+	EventDays int
+}
+
+// Window is a span of created_at values, [From, To).
+type Window struct {
+	From, To time.Time
+}
+
+// DefaultWindow is the created_at window of catalog rows: five years of 365
+// days from 2020-01-01.
+func DefaultWindow(zone *time.Location) Window {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, zone)
+	return Window{From: from, To: from.AddDate(0, 0, 5*365)}
+}
+
+// DefaultEventEnd is the end of the default book_events window: the end of
+// DefaultWindow, so the events follow the catalog they reference.
+func DefaultEventEnd(zone *time.Location) time.Time {
+	return DefaultWindow(zone).To
+}
+
+// Window returns the created_at window of a catalog table.
+func (d Dataset) Window(table string) Window {
+	if w, ok := d.Windows[table]; ok {
+		return w
+	}
+	return DefaultWindow(d.Zone)
+}
+
+// id draws a 1-based id below n+1 for column.
+func (d Dataset) id(rng *rand.Rand, column string, n int) int {
+	return d.Dists.get(column).Int(rng, n) + 1
+}
+
+// catalogTime draws a whole hour in the window of column's table.
+func (d Dataset) catalogTime(rng *rand.Rand, column string) time.Time {
+	table, _, _ := strings.Cut(column, ".")
+	w := d.Window(table)
+	hours := int(w.To.Sub(w.From) / time.Hour)
+	return w.From.Add(time.Duration(d.Dists.get(column).Int(rng, hours)) * time.Hour)
+}
+
+// EventStart is the beginning of the event window.
+func (d Dataset) EventStart() time.Time {
+	return d.EventEnd.AddDate(0, 0, -d.EventDays)
+}
+
+func (d Dataset) Author(rng *rand.Rand, i int) []interface{} {
+	return []interface{}{i + 1, fmt.Sprintf("Author %d", i+1), d.catalogTime(rng, "authors.created_at")}
+}
+
+func (d Dataset) Tag(rng *rand.Rand, i int) []interface{} {
+	category := tagCategories[rng.Intn(len(tagCategories))]
+	return []interface{}{i + 1, fmt.Sprintf("%s-%d", category, i+1)}
+}
+
+func (d Dataset) Book(rng *rand.Rand, i int) []interface{} {
+	title := fmt.Sprintf("%s %s Vol.%d",
+		titles[rng.Intn(len(titles))],
+		subjects[rng.Intn(len(subjects))],
+		i+1)
+	authorID := d.id(rng, "books.author_id", d.Authors)
+	return []interface{}{i + 1, title, authorID, d.catalogTime(rng, "books.created_at")}
+}
+
+func (d Dataset) Event(rng *rand.Rand, i int) []interface{} {
+	// 90% views, 10% purchases
+	eventType := events.TypeView
+	if rng.Intn(10) == 0 {
+		eventType = events.TypePurchase
+	}
+	window := d.EventDays * 24 * 3600
+	createdAt := d.EventStart().Add(time.Duration(d.Dists.get("book_events.created_at").Int(rng, window)) * time.Second)
+	return []interface{}{i + 1, d.id(rng, "book_events.book_id", d.Books), eventType, createdAt}
+}
+
+// Extra returns the generator of a column that table adds to its base. The
+// distribution is Dists["table.column"] or the column's default. Callers
+// draw extras from a stream of their own so the base columns stay the same
+// as in the base table.
+func (d Dataset) Extra(table, column string) (func(rng *rand.Rand) interface{}, error) {
+	c, ok := extraColumns[column]
+	if !ok {
+		return nil, fmt.Errorf("%s.%s: no generator for this column", table, column)
+	}
+	dist := c.dist
+	if custom, ok := d.Dists[table+"."+column]; ok {
+		dist = custom
+	}
+	return func(rng *rand.Rand) interface{} {
+		return dist.Int(rng, c.n)
+	}, nil
+}
@@ -0,0 +1,84 @@
+package datagen
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testDataset() Dataset {
+	return Dataset{
+		Authors:   100,
+		Books:     1000,
+		Tags:      50,
+		Zone:      time.UTC,
+		EventEnd:  time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		EventDays: 30,
+	}
+}
+
+func TestStreamsAreReproducible(t *testing.T) {
+	ds := testDataset()
+	gen := func(seed int64, table string, worker int) [][]interface{} {
+		rng := NewStream(seed, table, worker)
+		rows := make([][]interface{}, 100)
+		for i := range rows {
+			rows[i] = ds.Book(rng, i)
+		}
+		return rows
+	}
+
+	a := gen(42, "books", 3)
+	if b := gen(42, "books", 3); !reflect.DeepEqual(a, b) {
+		t.Error("same seed, table and worker generated different rows")
+	}
+	for name, other := range map[string][][]interface{}{
+		"seed":   gen(43, "books", 3),
+		"table":  gen(42, "books_hash", 3),
+		"worker": gen(42, "books", 4),
+	} {
+		if reflect.DeepEqual(a, other) {
+			t.Errorf("changing the %s did not change the rows", name)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	ds := testDataset()
+	rng := NewStream(1, "t", 0)
+
+	for i := 0; i < 1000; i++ {
+		book := ds.Book(rng, i)
+		if len(book) != len(BookColumns) || book[0] != i+1 {
+			t.Fatalf("book %d = %v", i, book)
+		}
+		if a := book[2].(int); a < 1 || a > ds.Authors {
+			t.Fatalf("author_id %d out of range", a)
+		}
+		if at := book[3].(time.Time); at.Year() < 2020 || at.Year() > 2024 {
+			t.Fatalf("created_at %v out of range", at)
+		}
+
+		ev := ds.Event(rng, i)
+		if at := ev[3].(time.Time); at.Before(ds.EventStart()) || !at.Before(ds.EventEnd) {
+			t.Fatalf("event at %v outside [%v, %v)", at, ds.EventStart(), ds.EventEnd)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	ds := testDataset()
+	w := Window{From: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)}
+	ds.Windows = map[string]Window{"books": w}
+	rng := NewStream(1, "books", 0)
+
+	for i := 0; i < 1000; i++ {
+		if at := ds.Book(rng, i)[3].(time.Time); at.Before(w.From) || !at.Before(w.To) {
+			t.Fatalf("created_at %v outside %v..%v", at, w.From, w.To)
+		}
+	}
+	// Other tables keep the default
+	if got := ds.Window("authors"); got != DefaultWindow(time.UTC) {
+		t.Errorf("authors window = %v", got)
+	}
+}
@@ -0,0 +1,290 @@
+package datagen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dist picks a value in [0, n): an id offset or a time offset within a
+// window. Implementations are stateless and safe for concurrent use; all
+// randomness comes from rng.
+type Dist interface {
+	Int(rng *rand.Rand, n int) int
+	// String returns the spec ParseDist accepts.
+	String() string
+}
+
+// Uniform picks every value with the same probability.
+type Uniform struct{}
+
+func (Uniform) Int(rng *rand.Rand, n int) int { return rng.Intn(n) }
+func (Uniform) String() string                { return "uniform" }
+
+// Zipf picks value rank r with probability proportional to 1/r^S, so a few
+// values are hot and the rest form a long tail. Ranks are spread over [0, n)
+// by a fixed permutation so the hot values are not all at the low end (e.g.
+// in the first RANGE partition).
+type Zipf struct {
+	S float64
+}
+
+func (z Zipf) Int(rng *rand.Rand, n int) int {
+	// Inverse CDF of the continuous power law on [1, n+1)
+	u := rng.Float64()
+	var x float64
+	if math.Abs(z.S-1) < 1e-9 {
+		x = math.Pow(float64(n+1), u)
+	} else {
+		a := 1 - z.S
+		x = math.Pow(u*(math.Pow(float64(n+1), a)-1)+1, 1/a)
+	}
+	return scatter(clamp(int(x)-1, n), n)
+}
+
+func (z Zipf) String() string { return "zipf:" + formatFloat(z.S) }
+
+// Normal picks values around Mean with standard deviation StdDev, both as
+// fractions of the range. Values outside the range are drawn again.
+type Normal struct {
+	Mean, StdDev float64
+}
+
+func (d Normal) Int(rng *rand.Rand, n int) int {
+	for i := 0; i < 100; i++ {
+		x := rng.NormFloat64()*d.StdDev + d.Mean
+		if x >= 0 && x < 1 {
+			return clamp(int(x*float64(n)), n)
+		}
+	}
+	return clamp(int(d.Mean*float64(n)), n)
+}
+
+func (d Normal) String() string {
+	return "normal:" + formatFloat(d.Mean) + "," + formatFloat(d.StdDev)
+}
+
+// Growth picks later values more often: the density grows exponentially
+// and the end of the range is e^Rate times as likely as the start. On a
+// time window this models data that grows year over year; on ids it makes
+// newer rows more popular.
+type Growth struct {
+	Rate float64
+}
+
+func (g Growth) Int(rng *rand.Rand, n int) int {
+	u := rng.Float64()
+	x := math.Log1p(u*math.Expm1(g.Rate)) / g.Rate
+	return clamp(int(x*float64(n)), n)
+}
+
+func (g Growth) String() string { return "growth:" + formatFloat(g.Rate) }
+
+// Weighted picks value i with probability proportional to Weights[i], for
+// categorical columns such as a status. Values past the range are never
+// picked.
+type Weighted struct {
+	Weights []float64
+}
+
+func (d Weighted) Int(rng *rand.Rand, n int) int {
+	weights := d.Weights
+	if len(weights) > n {
+		weights = weights[:n]
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := rng.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	return len(weights) - 1
+}
+
+func (d Weighted) String() string {
+	specs := make([]string, len(d.Weights))
+	for i, w := range d.Weights {
+		specs[i] = formatFloat(w)
+	}
+	return "weights:" + strings.Join(specs, ",")
+}
+
+// ParseDist parses "uniform", "zipf:S", "normal[:MEAN,STDDEV]",
+// "growth[:RATE]" or "weights:W0,W1,...".
+func ParseDist(spec string) (Dist, error) {
+	name, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	var args []float64
+	if params != "" {
+		for _, p := range strings.Split(params, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return nil, fmt.Errorf("distribution %q: %w", spec, err)
+			}
+			args = append(args, f)
+		}
+	}
+	bad := func(format string) (Dist, error) {
+		return nil, fmt.Errorf("distribution %q: want %s", spec, format)
+	}
+
+	switch name {
+	case "uniform":
+		if len(args) != 0 {
+			return bad("uniform")
+		}
+		return Uniform{}, nil
+	case "zipf":
+		if len(args) != 1 || args[0] <= 0 {
+			return bad("zipf:S with S > 0, e.g. zipf:1.1")
+		}
+		return Zipf{S: args[0]}, nil
+	case "normal":
+		switch len(args) {
+		case 0:
+			return Normal{Mean: 0.5, StdDev: 0.15}, nil
+		case 2:
+			if args[1] <= 0 {
+				return bad("normal:MEAN,STDDEV with STDDEV > 0")
+			}
+			return Normal{Mean: args[0], StdDev: args[1]}, nil
+		}
+		return bad("normal:MEAN,STDDEV as fractions of the range, e.g. normal:0.5,0.15")
+	case "growth":
+		switch {
+		case len(args) == 0:
+			return Growth{Rate: 3}, nil
+		case len(args) == 1 && args[0] > 0:
+			return Growth{Rate: args[0]}, nil
+		}
+		return bad("growth:RATE with RATE > 0, e.g. growth:3")
+	case "weights":
+		total := 0.0
+		for _, w := range args {
+			if w < 0 {
+				return bad("weights:W0,W1,... with W >= 0")
+			}
+			total += w
+		}
+		if total == 0 {
+			return bad("weights:W0,W1,... with at least one W > 0, e.g. weights:5,80,10,5")
+		}
+		return Weighted{Weights: args}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q (uniform, zipf, normal, growth, weights)", spec)
+}
+
+// DistColumns are the columns whose values can be drawn from a Dist, as
+// "table.column".
+var DistColumns = []string{
+	"authors.created_at",
+	"books.author_id",
+	"books.created_at",
+	"book_tags.book_id",
+	"book_tags.tag_id",
+	"book_tags.created_at",
+	"author_tags.author_id",
+	"author_tags.tag_id",
+	"author_tags.created_at",
+	"book_events.book_id",
+	"book_events.created_at",
+}
+
+// extraColumns are the columns partitioned variants add to their base table
+// (variant.Variant.Extra): the number of values each takes and their default
+// distribution.
+var extraColumns = map[string]struct {
+	n    int
+	dist Dist
+}{
+	// 0:draft, 1:published, 2:archived, 3:deleted
+	"status": {4, Weighted{Weights: []float64{5, 80, 10, 5}}},
+	// Region codes 0-9
+	"region": {10, Uniform{}},
+}
+
+// Dists maps "table.column" to its distribution. Columns not in the map are
+// uniform.
+type Dists map[string]Dist
+
+// Set parses "table.column=spec", so Dists can be a repeated flag.
+func (d Dists) Set(s string) error {
+	column, spec, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%q: want table.column=distribution", s)
+	}
+	_, name, _ := strings.Cut(column, ".")
+	_, extra := extraColumns[name]
+	if !contains(DistColumns, column) && !extra {
+		return fmt.Errorf("%q: unknown column (known: %s, or TABLE.status / TABLE.region of a variant)", column, strings.Join(DistColumns, ", "))
+	}
+	dist, err := ParseDist(spec)
+	if err != nil {
+		return err
+	}
+	d[column] = dist
+	return nil
+}
+
+func (d Dists) String() string {
+	specs := make([]string, 0, len(d))
+	for column, dist := range d {
+		specs = append(specs, column+"="+dist.String())
+	}
+	sort.Strings(specs)
+	return strings.Join(specs, " ")
+}
+
+func (d Dists) get(column string) Dist {
+	if dist, ok := d[column]; ok {
+		return dist
+	}
+	return Uniform{}
+}
+
+// scatter maps rank to a value in [0, n) with a fixed permutation: a
+// multiplicative step coprime with n visits every value exactly once.
+func scatter(rank, n int) int {
+	step := 2654435761 % n
+	for gcd(step, n) != 1 {
+		step++
+	}
+	return int((uint64(rank)*uint64(step) + uint64(n)/2) % uint64(n))
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func clamp(x, n int) int {
+	if x < 0 {
+		return 0
+	}
+	if x >= n {
+		return n - 1
+	}
+	return x
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package datagen
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// histogram draws samples from d over [0, n).
+func histogram(t *testing.T, d Dist, n, samples int) []int {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	counts := make([]int, n)
+	for i := 0; i < samples; i++ {
+		v := d.Int(rng, n)
+		if v < 0 || v >= n {
+			t.Fatalf("%s: %d out of [0, %d)", d, v, n)
+		}
+		counts[v]++
+	}
+	return counts
+}
+
+// topShare is the fraction of samples taken by the k most frequent values.
+func topShare(counts []int, k int) float64 {
+	sorted := append([]int(nil), counts...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	total, top := 0, 0
+	for i, c := range sorted {
+		total += c
+		if i < k {
+			top += c
+		}
+	}
+	return float64(top) / float64(total)
+}
+
+// halfShare is the fraction of samples in the upper half of the range.
+func halfShare(counts []int) float64 {
+	total, upper := 0, 0
+	for i, c := range counts {
+		total += c
+		if i >= len(counts)/2 {
+			upper += c
+		}
+	}
+	return float64(upper) / float64(total)
+}
+
+func TestDistributions(t *testing.T) {
+	const n, samples = 1000, 100000
+
+	if share := topShare(histogram(t, Uniform{}, n, samples), 10); share > 0.02 {
+		t.Errorf("uniform: top 1%% of values take %.2f", share)
+	}
+
+	zipf := histogram(t, Zipf{S: 1.1}, n, samples)
+	if share := topShare(zipf, 10); share < 0.3 {
+		t.Errorf("zipf: top 1%% of values take only %.2f", share)
+	}
+	// The hottest value is spread away from 0
+	if zipf[0] > samples/100 {
+		t.Errorf("zipf: value 0 has %d samples, ranks are not scattered", zipf[0])
+	}
+
+	normal := histogram(t, Normal{Mean: 0.5, StdDev: 0.1}, n, samples)
+	middle := 0
+	for _, c := range normal[300:700] {
+		middle += c
+	}
+	if share := float64(middle) / samples; share < 0.9 {
+		t.Errorf("normal: middle 40%% has %.2f of samples", share)
+	}
+
+	// e^3 ≈ 20: the upper half gets about 82%
+	if share := halfShare(histogram(t, Growth{Rate: 3}, n, samples)); share < 0.75 || share > 0.88 {
+		t.Errorf("growth: upper half has %.2f of samples", share)
+	}
+
+	weighted := histogram(t, Weighted{Weights: []float64{1, 0, 3, 5}}, 3, samples)
+	if weighted[1] != 0 || weighted[2] < samples*7/10 {
+		t.Errorf("weighted: %v, want about 1:0:3 and nothing past the range", weighted)
+	}
+}
+
+func TestExtra(t *testing.T) {
+	ds := Dataset{Dists: Dists{}}
+	if err := ds.Dists.Set("books_list.status=weights:0,1"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := ds.Extra("books_list", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	region, err := ds.Extra("author_tags_list", "region")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if v := status(rng); v != 1 {
+			t.Fatalf("status = %v, want 1", v)
+		}
+		if v := region(rng).(int); v < 0 || v > 9 {
+			t.Fatalf("region = %d", v)
+		}
+	}
+	if _, err := ds.Extra("books_list", "nope"); err == nil {
+		t.Error("Extra for an unknown column succeeded")
+	}
+}
+
+func TestScatterIsPermutation(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 1000, 4096} {
+		seen := make(map[int]bool, n)
+		for r := 0; r < n; r++ {
+			seen[scatter(r, n)] = true
+		}
+		if len(seen) != n {
+			t.Errorf("scatter over %d hits %d values", n, len(seen))
+		}
+	}
+}
+
+func TestParseDist(t *testing.T) {
+	for spec, want := range map[string]string{
+		"uniform":           "uniform",
+		"zipf:1.1":          "zipf:1.1",
+		"normal":            "normal:0.5,0.15",
+		"normal:0.8, 0.05":  "normal:0.8,0.05",
+		"growth":            "growth:3",
+		"growth:1.5":        "growth:1.5",
+		"weights:5,80,10,5": "weights:5,80,10,5",
+	} {
+		d, err := ParseDist(spec)
+		if err != nil {
+			t.Errorf("ParseDist(%q): %v", spec, err)
+			continue
+		}
+		if d.String() != want {
+			t.Errorf("ParseDist(%q) = %s, want %s", spec, d, want)
+		}
+	}
+
+	for _, spec := range []string{"", "pareto", "zipf", "zipf:0", "zipf:x", "normal:0.5", "growth:-1", "uniform:1", "weights", "weights:0,0", "weights:1,-1"} {
+		if _, err := ParseDist(spec); err == nil {
+			t.Errorf("ParseDist(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestDistsFlag(t *testing.T) {
+	d := Dists{}
+	if err := d.Set("books.author_id=zipf:1.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("books.created_at=growth:2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.String(); got != "books.author_id=zipf:1.2 books.created_at=growth:2" {
+		t.Errorf("String = %q", got)
+	}
+	if err := d.Set("books_list.status=weights:1,1"); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"books.title=uniform", "books.author_id", "books.author_id=nope", "status=uniform"} {
+		if err := d.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded", bad)
+		}
+	}
+}
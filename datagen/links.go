@@ -0,0 +1,102 @@
+package datagen
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Links generates a link table such as book_tags: distinct (owner, target)
+// pairs. The number of links of each owner is allocated up front, so the
+// table gets exactly the requested number of rows without duplicates to
+// drop, and each owner's targets are sampled without replacement.
+type Links struct {
+	Table string
+	// Owners and Targets are the number of owner and target ids; ids start
+	// at 1.
+	Owners  int
+	Targets int
+
+	d Dataset
+	// Dists keys of the owner, target and created_at columns
+	ownerColumn, targetColumn, timeColumn string
+}
+
+func (d Dataset) BookTags() Links {
+	return Links{Table: "book_tags", Owners: d.Books, Targets: d.Tags, d: d,
+		ownerColumn: "book_tags.book_id", targetColumn: "book_tags.tag_id", timeColumn: "book_tags.created_at"}
+}
+
+func (d Dataset) AuthorTags() Links {
+	return Links{Table: "author_tags", Owners: d.Authors, Targets: d.Tags, d: d,
+		ownerColumn: "author_tags.author_id", targetColumn: "author_tags.tag_id", timeColumn: "author_tags.created_at"}
+}
+
+// Allocate returns the number of links of each owner, count in total. Each
+// link's owner is drawn from the owner column's distribution; an owner that
+// already links every target is drawn again.
+func (l Links) Allocate(seed int64, count int) ([]int32, error) {
+	if l.Owners <= 0 || l.Targets <= 0 {
+		return nil, fmt.Errorf("%s: need owners and targets to link", l.Table)
+	}
+	if capacity := int64(l.Owners) * int64(l.Targets); int64(count) > capacity {
+		return nil, fmt.Errorf("%s: %d links requested, but %d owners × %d targets allow only %d",
+			l.Table, count, l.Owners, l.Targets, capacity)
+	}
+
+	rng := NewStream(seed, l.Table+"/allocate", 0)
+	dist := l.d.Dists.get(l.ownerColumn)
+	per := make([]int32, l.Owners)
+	max := int32(l.Targets)
+
+	placed, misses := 0, 0
+	for placed < count {
+		o := dist.Int(rng, l.Owners)
+		if per[o] < max {
+			per[o]++
+			placed++
+			misses = 0
+			continue
+		}
+		// Nearly full: fill the remaining owners in order instead of
+		// drawing forever
+		if misses++; misses > 1000 {
+			for o := 0; placed < count; o = (o + 1) % l.Owners {
+				if per[o] < max {
+					per[o]++
+					placed++
+				}
+			}
+		}
+	}
+	return per, nil
+}
+
+// Rows returns the n links of the owner with 0-based index owner, in the
+// order of BookTagColumns / AuthorTagColumns. Targets are drawn from the
+// target column's distribution without replacement.
+func (l Links) Rows(rng *rand.Rand, owner, n int) [][]interface{} {
+	dist := l.d.Dists.get(l.targetColumn)
+	seen := make(map[int]bool, n)
+	targets := make([]int, 0, n)
+	for tries := 0; len(targets) < n && tries < 20*n+100; tries++ {
+		t := dist.Int(rng, l.Targets)
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	// A skewed distribution may not reach every target of an owner with
+	// many links: take the rest in order from a random start
+	for t := rng.Intn(l.Targets); len(targets) < n; t = (t + 1) % l.Targets {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	rows := make([][]interface{}, n)
+	for i, t := range targets {
+		rows[i] = []interface{}{owner + 1, t + 1, l.d.catalogTime(rng, l.timeColumn)}
+	}
+	return rows
+}
@@ -0,0 +1,72 @@
+package datagen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	for name, dists := range map[string]Dists{
+		"uniform": {},
+		"skewed":  {"book_tags.book_id": Zipf{S: 1.5}, "book_tags.tag_id": Zipf{S: 1.2}},
+	} {
+		ds := testDataset()
+		ds.Books, ds.Tags = 200, 20
+		ds.Dists = dists
+		links := ds.BookTags()
+
+		// Close to capacity, so owners fill up and targets run out
+		const count = 3500
+		per, err := links.Allocate(7, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for _, n := range per {
+			if n < 0 || int(n) > ds.Tags {
+				t.Fatalf("%s: owner has %d links, max %d", name, n, ds.Tags)
+			}
+			total += int(n)
+		}
+		if total != count {
+			t.Errorf("%s: allocated %d links, want %d", name, total, count)
+		}
+
+		again, _ := links.Allocate(7, count)
+		if !reflect.DeepEqual(per, again) {
+			t.Errorf("%s: allocation is not reproducible", name)
+		}
+
+		rng := NewStream(7, "book_tags", 0)
+		seen := make(map[[2]int]bool)
+		for owner, n := range per {
+			rows := links.Rows(rng, owner, int(n))
+			if len(rows) != int(n) {
+				t.Fatalf("%s: owner %d got %d rows, want %d", name, owner, len(rows), n)
+			}
+			for _, row := range rows {
+				pair := [2]int{row[0].(int), row[1].(int)}
+				if pair[0] != owner+1 || pair[1] < 1 || pair[1] > ds.Tags || seen[pair] {
+					t.Fatalf("%s: bad or duplicate pair %v", name, pair)
+				}
+				seen[pair] = true
+			}
+		}
+	}
+}
+
+func TestLinksCapacity(t *testing.T) {
+	ds := testDataset()
+	ds.Books, ds.Tags = 10, 5
+	if _, err := ds.BookTags().Allocate(1, 51); err == nil {
+		t.Error("allocating more links than pairs succeeded")
+	}
+	if per, err := ds.BookTags().Allocate(1, 50); err != nil || per[3] != 5 {
+		t.Errorf("full allocation = %v, %v", per, err)
+	}
+
+	ds.Authors = 0
+	if _, err := ds.AuthorTags().Allocate(1, 1); err == nil {
+		t.Error("allocating links without owners succeeded")
+	}
+}
@@ -1,36 +1,50 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/sters/try-mysql-partitioning/config"
 )
 
+// DB is the primary (writer) connection pool.
 var DB *sql.DB
 
-func Init() error {
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "3306")
-	user := getEnv("DB_USER", "app")
-	password := getEnv("DB_PASSWORD", "app")
-	dbname := getEnv("DB_NAME", "bookdb")
+// Execer is satisfied by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Queryer is satisfied by *sql.DB and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local",
-		user, password, host, port, dbname)
+// Init connects using cfg, including its read replicas.
+func Init(cfg config.DB) error {
+	if window, err := time.ParseDuration(os.Getenv("DB_STICKY_WINDOW")); err == nil {
+		StickyWindow = window
+	}
+	if n, err := strconv.Atoi(os.Getenv("DB_TX_MAX_ATTEMPTS")); err == nil && n > 0 {
+		TxMaxAttempts = n
+	}
+	return Open(cfg)
+}
 
+// Open connects to the primary and to each of cfg.Replicas. The primary must
+// be reachable; replicas that are down or lagging are marked unhealthy and
+// retried by the background health check.
+func Open(cfg config.DB) error {
 	var err error
-	DB, err = sql.Open("mysql", dsn)
+	DB, err = cfg.Open()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	DB.SetMaxOpenConns(100)
-	DB.SetMaxIdleConns(10)
-	DB.SetConnMaxLifetime(time.Hour)
-
 	// Wait for database to be ready
 	for i := 0; i < 30; i++ {
 		err = DB.Ping()
@@ -44,18 +58,50 @@ func Init() error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	MaxReplicaLag = cfg.MaxReplicaLag
+	for i, spec := range cfg.Replicas {
+		pool, err := cfg.OpenReplica(spec)
+		if err != nil {
+			return fmt.Errorf("replica %d: failed to open database: %w", i, err)
+		}
+		r := &replica{name: fmt.Sprintf("replica-%d", i), db: pool}
+		r.check()
+		replicas = append(replicas, r)
+	}
+
+	if len(replicas) > 0 {
+		startHealthCheck(replicaCheckInterval)
+	}
+
 	return nil
 }
 
-func Close() {
-	if DB != nil {
-		DB.Close()
+// Writer returns the pool that all writes must go to.
+func Writer() *sql.DB {
+	return DB
+}
+
+// Reader returns a pool suitable for reads. It picks a healthy replica, or
+// the primary if there is none, ctx carries a recent write (see
+// WithLastWrite) or ctx asks for the primary (see WithPrimary).
+func Reader(ctx context.Context) *sql.DB {
+	if Sticky(ctx) || wantsPrimary(ctx) {
+		return DB
+	}
+	if r := nextHealthyReplica(); r != nil {
+		return r.db
 	}
+	return DB
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func Close() {
+	stopHealthCheck()
+	for _, r := range replicas {
+		r.db.Close()
+	}
+	replicas = nil
+
+	if DB != nil {
+		DB.Close()
 	}
-	return defaultValue
 }
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const replicaCheckInterval = 5 * time.Second
+
+// StickyWindow is how long reads stay on the primary after a write made by
+// the same client, so it can read its own writes despite replication lag.
+// Zero disables stickiness.
+var StickyWindow = 5 * time.Second
+
+// MaxReplicaLag is how far a replica may fall behind its source before reads
+// stop going to it. Open sets it from config.DB.
+var MaxReplicaLag = 10 * time.Second
+
+var (
+	replicas    []*replica
+	nextReplica uint64
+
+	healthStop chan struct{}
+	healthWG   sync.WaitGroup
+)
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+	if err == nil {
+		err = checkLag(ctx, r.db)
+	}
+	healthy := int32(0)
+	if err == nil {
+		healthy = 1
+	}
+
+	if old := atomic.SwapInt32(&r.healthy, healthy); old != healthy {
+		if err != nil {
+			log.Printf("%s marked unhealthy: %v", r.name, err)
+		} else {
+			log.Printf("%s marked healthy", r.name)
+		}
+	}
+}
+
+// checkLag fails unless the server is replicating and no more than
+// MaxReplicaLag behind its source. SHOW REPLICA STATUS needs MySQL 8.0.22
+// and the REPLICATION CLIENT privilege.
+func checkLag(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return fmt.Errorf("replica status: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("not a replica")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+
+	for i, c := range columns {
+		if c != "Seconds_Behind_Source" {
+			continue
+		}
+		// NULL while the SQL or I/O thread is stopped
+		if values[i] == nil {
+			return fmt.Errorf("replication is not running")
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return fmt.Errorf("Seconds_Behind_Source %q: %w", values[i], err)
+		}
+		if lag := time.Duration(seconds) * time.Second; lag > MaxReplicaLag {
+			return fmt.Errorf("%v behind the source, more than %v", lag, MaxReplicaLag)
+		}
+		return nil
+	}
+	return fmt.Errorf("no Seconds_Behind_Source in the replica status")
+}
+
+func nextHealthyReplica() *replica {
+	n := len(replicas)
+	if n == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint64(&nextReplica, 1)
+	for i := 0; i < n; i++ {
+		r := replicas[(start+uint64(i))%uint64(n)]
+		if r.isHealthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+func startHealthCheck(interval time.Duration) {
+	healthStop = make(chan struct{})
+	healthWG.Add(1)
+
+	go func() {
+		defer healthWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-healthStop:
+				return
+			case <-ticker.C:
+				for _, r := range replicas {
+					r.check()
+				}
+			}
+		}
+	}()
+}
+
+func stopHealthCheck() {
+	if healthStop == nil {
+		return
+	}
+	close(healthStop)
+	healthWG.Wait()
+	healthStop = nil
+}
+
+type lastWriteKey struct{}
+
+// WithLastWrite records when the caller last wrote, for read-your-writes
+// stickiness in Reader. An earlier time never replaces a later one.
+func WithLastWrite(ctx context.Context, t time.Time) context.Context {
+	if prev, ok := ctx.Value(lastWriteKey{}).(time.Time); ok && prev.After(t) {
+		return ctx
+	}
+	return context.WithValue(ctx, lastWriteKey{}, t)
+}
+
+// Sticky reports whether ctx carries a write recent enough that reads must
+// go to the primary.
+func Sticky(ctx context.Context) bool {
+	if StickyWindow <= 0 || ctx == nil {
+		return false
+	}
+	t, ok := ctx.Value(lastWriteKey{}).(time.Time)
+	return ok && time.Since(t) < StickyWindow
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes Reader return the primary for ctx, for reads whose
+// results outlive the request, such as cache fills.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func wantsPrimary(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(primaryKey{}) != nil
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TxMaxAttempts is how many times InTx runs a transaction that keeps failing
+// with a deadlock or lock wait timeout. 1 disables retries.
+var TxMaxAttempts = 3
+
+// TxRetryBaseDelay and TxRetryMaxDelay bound the backoff between attempts.
+// The n-th retry sleeps a random duration up to min(base*2^n, max).
+var (
+	TxRetryBaseDelay = 10 * time.Millisecond
+	TxRetryMaxDelay  = 500 * time.Millisecond
+)
+
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// RetryStats counts retries of one operation.
+type RetryStats struct {
+	Deadlocks        uint64 `json:"deadlocks"`
+	LockWaitTimeouts uint64 `json:"lock_wait_timeouts"`
+	// Retries is the number of attempts after the first.
+	Retries uint64 `json:"retries"`
+	// Recovered counts operations that succeeded after at least one retry.
+	Recovered uint64 `json:"recovered"`
+	// Exhausted counts operations that still failed after TxMaxAttempts.
+	Exhausted uint64 `json:"exhausted"`
+}
+
+var (
+	retryMu    sync.Mutex
+	retryStats = make(map[string]*RetryStats)
+)
+
+// InTx runs fn in a transaction on the primary, committing if fn succeeds.
+// If the transaction fails with a deadlock or lock wait timeout it is rolled
+// back and run again, up to TxMaxAttempts times, so fn must be safe to repeat:
+// it should only touch the database through tx and overwrite, not append to,
+// anything it captures. op names the operation in TxRetryStats.
+func InTx(ctx context.Context, op string, fn func(tx *sql.Tx) error) error {
+	return Retry(ctx, op, func() error {
+		tx, err := Writer().BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Retry calls fn until it returns nil, an error that IsRetryable rejects, or
+// TxMaxAttempts is reached, sleeping with jittered backoff in between.
+func Retry(ctx context.Context, op string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil {
+			if attempt > 0 {
+				countRetry(op, func(s *RetryStats) { s.Recovered++ })
+			}
+			return nil
+		}
+
+		code, ok := retryableCode(err)
+		if !ok {
+			return err
+		}
+		countRetry(op, func(s *RetryStats) {
+			if code == errDeadlock {
+				s.Deadlocks++
+			} else {
+				s.LockWaitTimeouts++
+			}
+		})
+
+		if attempt+1 >= TxMaxAttempts {
+			countRetry(op, func(s *RetryStats) { s.Exhausted++ })
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff(attempt)):
+		}
+		countRetry(op, func(s *RetryStats) { s.Retries++ })
+	}
+}
+
+// IsRetryable reports whether err is a deadlock or lock wait timeout.
+func IsRetryable(err error) bool {
+	_, ok := retryableCode(err)
+	return ok
+}
+
+func retryableCode(err error) (uint16, bool) {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return 0, false
+	}
+	switch myErr.Number {
+	case errDeadlock, errLockWaitTimeout:
+		return myErr.Number, true
+	}
+	return 0, false
+}
+
+func backoff(attempt int) time.Duration {
+	d := TxRetryMaxDelay
+	if attempt < 30 {
+		if exp := TxRetryBaseDelay << uint(attempt); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func countRetry(op string, update func(*RetryStats)) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+
+	s, ok := retryStats[op]
+	if !ok {
+		s = &RetryStats{}
+		retryStats[op] = s
+	}
+	update(s)
+}
+
+// TxRetryStats returns a snapshot of the retry counters by operation. Only
+// operations that have hit a retryable error appear.
+func TxRetryStats() map[string]RetryStats {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+
+	out := make(map[string]RetryStats, len(retryStats))
+	for op, s := range retryStats {
+		out[op] = *s
+	}
+	return out
+}
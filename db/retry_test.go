@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func withRetrySettings(t *testing.T, attempts int) {
+	t.Helper()
+	oldAttempts, oldBase, oldMax := TxMaxAttempts, TxRetryBaseDelay, TxRetryMaxDelay
+	TxMaxAttempts, TxRetryBaseDelay, TxRetryMaxDelay = attempts, time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		TxMaxAttempts, TxRetryBaseDelay, TxRetryMaxDelay = oldAttempts, oldBase, oldMax
+	})
+}
+
+func TestRetryRecovers(t *testing.T) {
+	withRetrySettings(t, 3)
+
+	calls := 0
+	err := Retry(context.Background(), "test.recovers", func() error {
+		calls++
+		switch calls {
+		case 1:
+			return &mysql.MySQLError{Number: errDeadlock}
+		case 2:
+			return &mysql.MySQLError{Number: errLockWaitTimeout}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	want := RetryStats{Deadlocks: 1, LockWaitTimeouts: 1, Retries: 2, Recovered: 1}
+	if got := TxRetryStats()["test.recovers"]; got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	withRetrySettings(t, 2)
+
+	calls := 0
+	err := Retry(context.Background(), "test.exhausted", func() error {
+		calls++
+		return &mysql.MySQLError{Number: errDeadlock}
+	})
+	if !IsRetryable(err) {
+		t.Fatalf("err = %v, want the deadlock", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+
+	want := RetryStats{Deadlocks: 2, Retries: 1, Exhausted: 1}
+	if got := TxRetryStats()["test.exhausted"]; got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestRetryOtherErrors(t *testing.T) {
+	withRetrySettings(t, 3)
+
+	calls := 0
+	dup := &mysql.MySQLError{Number: 1062}
+	err := Retry(context.Background(), "test.other", func() error {
+		calls++
+		return dup
+	})
+	if !errors.Is(err, dup) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the duplicate error after 1", err, calls)
+	}
+	if _, ok := TxRetryStats()["test.other"]; ok {
+		t.Error("non-retryable errors should not be counted")
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	withRetrySettings(t, 5)
+	TxRetryBaseDelay, TxRetryMaxDelay = time.Hour, time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Retry(ctx, "test.cancel", func() error {
+		calls++
+		cancel()
+		return &mysql.MySQLError{Number: errLockWaitTimeout}
+	})
+	if !IsRetryable(err) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the lock wait timeout after 1", err, calls)
+	}
+}
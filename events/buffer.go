@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+// ErrBufferFull is returned by Add when MaxPending events are waiting to be
+// written, e.g. because the database is down or slower than the load.
+var ErrBufferFull = errors.New("event buffer is full")
+
+// ErrBufferClosed is returned by Add after Close, when nothing would flush
+// the event any more.
+var ErrBufferClosed = errors.New("event buffer is closed")
+
+// WriteFunc writes one batch of events, retrying what can be retried.
+type WriteFunc func(ctx context.Context, evs []models.BookEvent) error
+
+// Buffer collects events in memory and writes them in batches, either when
+// BatchSize events are pending or every FlushInterval. A batch that fails is
+// put back and retried on the next flush. Once maxPending events wait
+// besides the batch being written, Add rejects new ones. Events still
+// buffered when the process dies are lost; Close flushes them on a clean
+// shutdown.
+type Buffer struct {
+	write         WriteFunc
+	batchSize     int
+	maxPending    int
+	flushInterval time.Duration
+
+	mu      sync.Mutex
+	pending []models.BookEvent
+	closed  bool
+	flushCh chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func NewBuffer(write WriteFunc, batchSize, maxPending int, flushInterval time.Duration) *Buffer {
+	if maxPending < batchSize {
+		maxPending = batchSize
+	}
+	b := &Buffer{
+		write:         write,
+		batchSize:     batchSize,
+		maxPending:    maxPending,
+		flushInterval: flushInterval,
+		pending:       make([]models.BookEvent, 0, batchSize),
+		flushCh:       make(chan struct{}, 1),
+		done:          make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Add queues an event, or returns ErrBufferFull or ErrBufferClosed. It never
+// blocks on the database.
+func (b *Buffer) Add(e models.BookEvent) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBufferClosed
+	}
+	if len(b.pending) >= b.maxPending {
+		b.mu.Unlock()
+		return ErrBufferFull
+	}
+	b.pending = append(b.pending, e)
+	full := len(b.pending) >= b.batchSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close stops the background writer after flushing what is pending. Events
+// added afterwards are rejected.
+func (b *Buffer) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.done)
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending) > 0 {
+		log.Printf("events: dropped %d events at shutdown", len(b.pending))
+	}
+}
+
+func (b *Buffer) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			b.flush()
+			return
+		case <-ticker.C:
+			b.flush()
+		case <-b.flushCh:
+			b.flush()
+		}
+	}
+}
+
+// flush writes batches until nothing is pending or a batch fails.
+func (b *Buffer) flush() {
+	for {
+		b.mu.Lock()
+		n := len(b.pending)
+		if n == 0 {
+			b.mu.Unlock()
+			return
+		}
+		if n > b.batchSize {
+			n = b.batchSize
+		}
+		batch := make([]models.BookEvent, n)
+		copy(batch, b.pending[:n])
+		b.pending = b.pending[n:]
+		b.mu.Unlock()
+
+		if err := b.write(context.Background(), batch); err != nil {
+			b.requeue(batch)
+			log.Printf("events: writing %d events failed, retrying on the next flush: %v", len(batch), err)
+			return
+		}
+	}
+}
+
+// requeue puts a failed batch back in front of the events added since.
+func (b *Buffer) requeue(batch []models.BookEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(batch, b.pending...)
+}
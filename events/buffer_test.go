@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+func TestBufferRejectsWhenFullAndRetriesFailedBatches(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fail    = true
+		written []models.BookEvent
+	)
+	write := func(ctx context.Context, evs []models.BookEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("down")
+		}
+		written = append(written, evs...)
+		return nil
+	}
+	b := NewBuffer(write, 2, 4, time.Hour)
+
+	for i := 1; i <= 4; i++ {
+		if err := b.Add(models.BookEvent{BookID: int64(i)}); err != nil {
+			t.Fatalf("Add %d: %v", i, err)
+		}
+	}
+	// Wait for the failed flushes to put their batches back
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := b.Add(models.BookEvent{BookID: 5})
+		if errors.Is(err, ErrBufferFull) {
+			break
+		}
+		if err != nil || time.Now().After(deadline) {
+			t.Fatalf("Add on a full buffer = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	b.Close()
+
+	seen := make(map[int64]bool)
+	for _, e := range written {
+		seen[e.BookID] = true
+	}
+	for i := int64(1); i <= 4; i++ {
+		if !seen[i] {
+			t.Errorf("event %d was not written after the failure: %v", i, written)
+		}
+	}
+}
+
+func TestBufferRejectsAfterClose(t *testing.T) {
+	var written int
+	b := NewBuffer(func(ctx context.Context, evs []models.BookEvent) error {
+		written += len(evs)
+		return nil
+	}, 10, 10, time.Hour)
+
+	if err := b.Add(models.BookEvent{BookID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	if err := b.Add(models.BookEvent{BookID: 2}); !errors.Is(err, ErrBufferClosed) {
+		t.Errorf("Add after Close = %v, want ErrBufferClosed", err)
+	}
+	if written != 1 {
+		t.Errorf("written %d events, want 1", written)
+	}
+}
@@ -0,0 +1,111 @@
+// Package events ingests book view/purchase events into book_events, an
+// append-only table RANGE-partitioned by day.
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+// Event type codes stored in book_events.event_type
+const (
+	TypeView     = 1
+	TypePurchase = 2
+)
+
+var typeNames = map[string]int{
+	"view":     TypeView,
+	"purchase": TypePurchase,
+}
+
+// ParseType converts an API event type name to its stored code.
+func ParseType(name string) (int, error) {
+	t, ok := typeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown event type: %q", name)
+	}
+	return t, nil
+}
+
+// DailyCount is the number of events of each type on one day.
+type DailyCount struct {
+	Date      string `json:"date"`
+	Views     int64  `json:"views"`
+	Purchases int64  `json:"purchases"`
+}
+
+type Summary struct {
+	BookID    int64        `json:"book_id"`
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Views     int64        `json:"views"`
+	Purchases int64        `json:"purchases"`
+	Daily     []DailyCount `json:"daily"`
+}
+
+// Summarize counts events for bookID in [from, to). The created_at range
+// lets MySQL prune to the daily partitions it covers.
+func Summarize(ctx context.Context, q db.Queryer, bookID int64, from, to time.Time) (Summary, error) {
+	s := Summary{BookID: bookID, From: from, To: to, Daily: []DailyCount{}}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT DATE(created_at) AS d, event_type, COUNT(*)
+		FROM book_events
+		WHERE book_id = ? AND created_at >= ? AND created_at < ?
+		GROUP BY d, event_type
+		ORDER BY d
+	`, bookID, from, to)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day time.Time
+		var eventType int
+		var count int64
+		if err := rows.Scan(&day, &eventType, &count); err != nil {
+			return s, err
+		}
+
+		date := day.Format("2006-01-02")
+		if len(s.Daily) == 0 || s.Daily[len(s.Daily)-1].Date != date {
+			s.Daily = append(s.Daily, DailyCount{Date: date})
+		}
+		d := &s.Daily[len(s.Daily)-1]
+
+		switch eventType {
+		case TypeView:
+			d.Views += count
+			s.Views += count
+		case TypePurchase:
+			d.Purchases += count
+			s.Purchases += count
+		}
+	}
+	return s, rows.Err()
+}
+
+// Insert writes events with a single multi-row INSERT.
+func Insert(ctx context.Context, ex db.Execer, evs []models.BookEvent) error {
+	if len(evs) == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO book_events (book_id, event_type, created_at) VALUES "
+	args := make([]interface{}, 0, len(evs)*3)
+	for i, e := range evs {
+		if i > 0 {
+			query += ","
+		}
+		query += "(?, ?, ?)"
+		args = append(args, e.BookID, e.Type, e.CreatedAt)
+	}
+
+	_, err := ex.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/audit"
+)
+
+// AuditHandler serves GET /audit?entity=&id=&from=&to=&limit=.
+// from/to default to the last 30 days so every query prunes to a few monthly
+// partitions.
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filter{
+		Entity: q.Get("entity"),
+		To:     time.Now(),
+		Limit:  100,
+	}
+
+	if s := q.Get("id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		f.EntityID = id
+	}
+	if s := q.Get("to"); s != "" {
+		t, err := parseTimeParam(s)
+		if err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+		f.To = t
+	}
+	f.From = f.To.AddDate(0, 0, -30)
+	if s := q.Get("from"); s != "" {
+		t, err := parseTimeParam(s)
+		if err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+		f.From = t
+	}
+	if l := q.Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			f.Limit = parsed
+		}
+	}
+
+	entries, err := dataStore.ListAudit(readContext(r), f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, entries)
+}
+
+// parseTimeParam accepts a date (2006-01-02) or an RFC 3339 timestamp.
+func parseTimeParam(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -1,14 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/sters/try-mysql-partitioning/db"
 	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/store"
 )
 
 func AuthorsHandler(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +47,12 @@ func AuthorHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listAuthors(w http.ResponseWriter, r *http.Request) {
+	expand, expanding, err := authorExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	limit := 100
 	offset := 0
 
@@ -62,36 +67,47 @@ func listAuthors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rows, err := db.DB.Query("SELECT id, name, created_at FROM authors ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	ctx := readContext(r)
+	authors, err := dataStore.ListAuthors(ctx, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var authors []models.Author
-	for rows.Next() {
-		var a models.Author
-		if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt); err != nil {
+	if expanding {
+		details, err := store.ExpandAuthors(ctx, dataStore, authors, expand)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		authors = append(authors, a)
+		respondJSON(w, details)
+		return
 	}
 
 	respondJSON(w, authors)
 }
 
 func getAuthor(w http.ResponseWriter, r *http.Request, id int64) {
-	var a models.Author
-	err := db.DB.QueryRow("SELECT id, name, created_at FROM authors WHERE id = ?", id).
-		Scan(&a.ID, &a.Name, &a.CreatedAt)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Author not found", http.StatusNotFound)
+	expand, expanding, err := authorExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	ctx := readContext(r)
+	a, err := dataStore.GetAuthor(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Author not found")
+		return
+	}
+
+	if expanding {
+		details, err := store.ExpandAuthors(ctx, dataStore, []models.Author{a}, expand)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, details[0])
 		return
 	}
 
@@ -107,17 +123,13 @@ func createAuthor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := db.DB.Exec("INSERT INTO authors (name) VALUES (?)", input.Name)
+	a, err := dataStore.CreateAuthor(r.Context(), change(r), input.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Author not found")
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	var a models.Author
-	db.DB.QueryRow("SELECT id, name, created_at FROM authors WHERE id = ?", id).
-		Scan(&a.ID, &a.Name, &a.CreatedAt)
-
+	markWrite(w)
 	w.WriteHeader(http.StatusCreated)
 	respondJSON(w, a)
 }
@@ -131,41 +143,30 @@ func updateAuthor(w http.ResponseWriter, r *http.Request, id int64) {
 		return
 	}
 
-	result, err := db.DB.Exec("UPDATE authors SET name = ? WHERE id = ?", input.Name, id)
+	a, err := dataStore.UpdateAuthor(r.Context(), change(r), id, input.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Author not found", http.StatusNotFound)
+		storeError(w, err, "Author not found")
 		return
 	}
 
-	getAuthor(w, r, id)
+	markWrite(w)
+	respondJSON(w, a)
 }
 
 func deleteAuthor(w http.ResponseWriter, r *http.Request, id int64) {
-	result, err := db.DB.Exec("DELETE FROM authors WHERE id = ?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Author not found", http.StatusNotFound)
+	if err := dataStore.DeleteAuthor(r.Context(), change(r), id); err != nil {
+		storeError(w, err, "Author not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleAuthorTags(w http.ResponseWriter, r *http.Request, authorID int64) {
 	switch r.Method {
 	case http.MethodGet:
-		listAuthorTags(w, authorID)
+		listAuthorTags(w, r, authorID)
 	case http.MethodPost:
 		addAuthorTag(w, r, authorID)
 	case http.MethodDelete:
@@ -174,33 +175,18 @@ func handleAuthorTags(w http.ResponseWriter, r *http.Request, authorID int64) {
 			http.Error(w, "Invalid tag ID", http.StatusBadRequest)
 			return
 		}
-		removeAuthorTag(w, authorID, tagID)
+		removeAuthorTag(w, r, authorID, tagID)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func listAuthorTags(w http.ResponseWriter, authorID int64) {
-	rows, err := db.DB.Query(`
-		SELECT t.id, t.name FROM tags t
-		INNER JOIN author_tags at ON t.id = at.tag_id
-		WHERE at.author_id = ?
-	`, authorID)
+func listAuthorTags(w http.ResponseWriter, r *http.Request, authorID int64) {
+	tags, err := dataStore.ListAuthorTags(readContext(r), authorID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var tags []models.Tag
-	for rows.Next() {
-		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		tags = append(tags, t)
-	}
 
 	respondJSON(w, tags)
 }
@@ -214,27 +200,21 @@ func addAuthorTag(w http.ResponseWriter, r *http.Request, authorID int64) {
 		return
 	}
 
-	_, err := db.DB.Exec("INSERT INTO author_tags (author_id, tag_id) VALUES (?, ?)", authorID, input.TagID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := dataStore.AddAuthorTag(r.Context(), change(r), authorID, input.TagID); err != nil {
+		storeError(w, err, "Tag association not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusCreated)
 }
 
-func removeAuthorTag(w http.ResponseWriter, authorID, tagID int64) {
-	result, err := db.DB.Exec("DELETE FROM author_tags WHERE author_id = ? AND tag_id = ?", authorID, tagID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Tag association not found", http.StatusNotFound)
+func removeAuthorTag(w http.ResponseWriter, r *http.Request, authorID, tagID int64) {
+	if err := dataStore.RemoveAuthorTag(r.Context(), change(r), authorID, tagID); err != nil {
+		storeError(w, err, "Tag association not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusNoContent)
 }
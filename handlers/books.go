@@ -1,14 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/sters/try-mysql-partitioning/db"
 	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/store"
 )
 
 func BooksHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +28,12 @@ func BookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check if this is an events sub-resource
+	if strings.Contains(r.URL.Path, "/events") {
+		handleBookEvents(w, r, id)
+		return
+	}
+
 	// Check if this is a tags sub-resource
 	if strings.Contains(r.URL.Path, "/tags") {
 		handleBookTags(w, r, id)
@@ -48,6 +53,12 @@ func BookHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listBooks(w http.ResponseWriter, r *http.Request) {
+	expand, expanding, err := bookExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	limit := 100
 	offset := 0
 
@@ -62,36 +73,47 @@ func listBooks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rows, err := db.DB.Query("SELECT id, title, author_id, created_at FROM books ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	ctx := readContext(r)
+	books, err := dataStore.ListBooks(ctx, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var books []models.Book
-	for rows.Next() {
-		var b models.Book
-		if err := rows.Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt); err != nil {
+	if expanding {
+		details, err := store.ExpandBooks(ctx, dataStore, books, expand)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		books = append(books, b)
+		respondJSON(w, details)
+		return
 	}
 
 	respondJSON(w, books)
 }
 
 func getBook(w http.ResponseWriter, r *http.Request, id int64) {
-	var b models.Book
-	err := db.DB.QueryRow("SELECT id, title, author_id, created_at FROM books WHERE id = ?", id).
-		Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Book not found", http.StatusNotFound)
+	expand, expanding, err := bookExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	ctx := readContext(r)
+	b, err := dataStore.GetBook(ctx, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Book not found")
+		return
+	}
+
+	if expanding {
+		details, err := store.ExpandBooks(ctx, dataStore, []models.Book{b}, expand)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, details[0])
 		return
 	}
 
@@ -108,17 +130,13 @@ func createBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := db.DB.Exec("INSERT INTO books (title, author_id) VALUES (?, ?)", input.Title, input.AuthorID)
+	b, err := dataStore.CreateBook(r.Context(), change(r), input.Title, input.AuthorID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Book not found")
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	var b models.Book
-	db.DB.QueryRow("SELECT id, title, author_id, created_at FROM books WHERE id = ?", id).
-		Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt)
-
+	markWrite(w)
 	w.WriteHeader(http.StatusCreated)
 	respondJSON(w, b)
 }
@@ -133,41 +151,30 @@ func updateBook(w http.ResponseWriter, r *http.Request, id int64) {
 		return
 	}
 
-	result, err := db.DB.Exec("UPDATE books SET title = ?, author_id = ? WHERE id = ?", input.Title, input.AuthorID, id)
+	b, err := dataStore.UpdateBook(r.Context(), change(r), id, input.Title, input.AuthorID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Book not found")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Book not found", http.StatusNotFound)
-		return
-	}
-
-	getBook(w, r, id)
+	markWrite(w)
+	respondJSON(w, b)
 }
 
 func deleteBook(w http.ResponseWriter, r *http.Request, id int64) {
-	result, err := db.DB.Exec("DELETE FROM books WHERE id = ?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Book not found", http.StatusNotFound)
+	if err := dataStore.DeleteBook(r.Context(), change(r), id); err != nil {
+		storeError(w, err, "Book not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleBookTags(w http.ResponseWriter, r *http.Request, bookID int64) {
 	switch r.Method {
 	case http.MethodGet:
-		listBookTags(w, bookID)
+		listBookTags(w, r, bookID)
 	case http.MethodPost:
 		addBookTag(w, r, bookID)
 	case http.MethodDelete:
@@ -176,33 +183,18 @@ func handleBookTags(w http.ResponseWriter, r *http.Request, bookID int64) {
 			http.Error(w, "Invalid tag ID", http.StatusBadRequest)
 			return
 		}
-		removeBookTag(w, bookID, tagID)
+		removeBookTag(w, r, bookID, tagID)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func listBookTags(w http.ResponseWriter, bookID int64) {
-	rows, err := db.DB.Query(`
-		SELECT t.id, t.name FROM tags t
-		INNER JOIN book_tags bt ON t.id = bt.tag_id
-		WHERE bt.book_id = ?
-	`, bookID)
+func listBookTags(w http.ResponseWriter, r *http.Request, bookID int64) {
+	tags, err := dataStore.ListBookTags(readContext(r), bookID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var tags []models.Tag
-	for rows.Next() {
-		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		tags = append(tags, t)
-	}
 
 	respondJSON(w, tags)
 }
@@ -216,27 +208,21 @@ func addBookTag(w http.ResponseWriter, r *http.Request, bookID int64) {
 		return
 	}
 
-	_, err := db.DB.Exec("INSERT INTO book_tags (book_id, tag_id) VALUES (?, ?)", bookID, input.TagID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := dataStore.AddBookTag(r.Context(), change(r), bookID, input.TagID); err != nil {
+		storeError(w, err, "Tag association not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusCreated)
 }
 
-func removeBookTag(w http.ResponseWriter, bookID, tagID int64) {
-	result, err := db.DB.Exec("DELETE FROM book_tags WHERE book_id = ? AND tag_id = ?", bookID, tagID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Tag association not found", http.StatusNotFound)
+func removeBookTag(w http.ResponseWriter, r *http.Request, bookID, tagID int64) {
+	if err := dataStore.RemoveBookTag(r.Context(), change(r), bookID, tagID); err != nil {
+		storeError(w, err, "Tag association not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/db"
+)
+
+// CacheStatsHandler reports the entity cache's counters, or zeros when the
+// store is not cached.
+func CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var stats cache.Stats
+	if c, ok := dataStore.(interface{ CacheStats() cache.Stats }); ok {
+		stats = c.CacheStats()
+	}
+	respondJSON(w, stats)
+}
+
+// RetryStatsHandler reports deadlock and lock wait timeout retries by store
+// operation.
+func RetryStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	respondJSON(w, db.TxRetryStats())
+}
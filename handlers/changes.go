@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// ChangesHandler serves the change feed: GET /changes?since=<event id>&limit=N.
+func ChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since int64
+	limit := 100
+
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	events, err := dataStore.ListChanges(readContext(r), since, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	next := since
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"events": events,
+		"next":   next,
+	})
+}
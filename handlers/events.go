@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/events"
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/store"
+)
+
+var eventBuffer *events.Buffer
+
+// SetEventBuffer makes POST /books/{id}/events buffer inserts in b. Without
+// a buffer every event is inserted synchronously.
+func SetEventBuffer(b *events.Buffer) {
+	eventBuffer = b
+}
+
+func handleBookEvents(w http.ResponseWriter, r *http.Request, bookID int64) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/events/summary") && r.Method == http.MethodGet:
+		bookEventSummary(w, r, bookID)
+	case strings.HasSuffix(r.URL.Path, "/events") && r.Method == http.MethodPost:
+		addBookEvent(w, r, bookID)
+	case strings.HasSuffix(r.URL.Path, "/events") || strings.HasSuffix(r.URL.Path, "/events/summary"):
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func addBookEvent(w http.ResponseWriter, r *http.Request, bookID int64) {
+	var input struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	eventType, err := events.ParseType(input.Type)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Events for unknown books would be counted forever; book_events has no
+	// foreign key because it is partitioned
+	if _, err := dataStore.GetBook(readContext(r), bookID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Book not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	e := models.BookEvent{BookID: bookID, Type: eventType, CreatedAt: time.Now().Truncate(time.Second)}
+	if eventBuffer != nil {
+		if err := eventBuffer.Add(e); err != nil {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	} else if err := dataStore.AddBookEvents(r.Context(), []models.BookEvent{e}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func bookEventSummary(w http.ResponseWriter, r *http.Request, bookID int64) {
+	q := r.URL.Query()
+	to := time.Now()
+	if s := q.Get("to"); s != "" {
+		t, err := parseTimeParam(s)
+		if err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -7)
+	if s := q.Get("from"); s != "" {
+		t, err := parseTimeParam(s)
+		if err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+
+	summary, err := dataStore.BookEventSummary(readContext(r), bookID, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, summary)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sters/try-mysql-partitioning/store"
+)
+
+// ExpandBooksLimit is the default cap on the books embedded per author by
+// ?expand=books; ?books_limit= changes it up to MaxExpandBooksLimit.
+var ExpandBooksLimit = 100
+
+const MaxExpandBooksLimit = 1000
+
+// parseExpand reads the comma-separated ?expand= list. Every name must be in
+// allowed.
+func parseExpand(r *http.Request, allowed ...string) (map[string]bool, error) {
+	expand := make(map[string]bool)
+	for _, name := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !contains(allowed, name) {
+			return nil, fmt.Errorf("unknown expand %q (allowed: %s)", name, strings.Join(allowed, ", "))
+		}
+		expand[name] = true
+	}
+	return expand, nil
+}
+
+func bookExpand(r *http.Request) (store.BookExpand, bool, error) {
+	expand, err := parseExpand(r, "author", "tags")
+	if err != nil {
+		return store.BookExpand{}, false, err
+	}
+	return store.BookExpand{Author: expand["author"], Tags: expand["tags"]}, len(expand) > 0, nil
+}
+
+func authorExpand(r *http.Request) (store.AuthorExpand, bool, error) {
+	expand, err := parseExpand(r, "books", "tags")
+	if err != nil {
+		return store.AuthorExpand{}, false, err
+	}
+	e := store.AuthorExpand{Books: expand["books"], Tags: expand["tags"], BooksLimit: ExpandBooksLimit}
+	if s := r.URL.Query().Get("books_limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxExpandBooksLimit {
+			return store.AuthorExpand{}, false, fmt.Errorf("books_limit must be between 1 and %d", MaxExpandBooksLimit)
+		}
+		e.BooksLimit = n
+	}
+	return e, len(expand) > 0, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
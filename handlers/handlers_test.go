@@ -0,0 +1,578 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/audit"
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/events"
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/outbox"
+	"github.com/sters/try-mysql-partitioning/store"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+// newTestServer serves the API from an in-memory store.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	prevStore, prevBuffer := dataStore, eventBuffer
+	dataStore = store.NewCached(store.NewMemory(), cache.NewLRU(100, time.Minute))
+	eventBuffer = nil
+
+	srv := httptest.NewServer(NewRouter())
+	t.Cleanup(func() {
+		srv.Close()
+		dataStore, eventBuffer = prevStore, prevBuffer
+	})
+	return srv
+}
+
+// do sends a request and decodes a JSON response into out, if given.
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out interface{}) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Actor", "tester")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s: status %d, want %d", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	srv := newTestServer(t)
+
+	var created models.Author
+	resp := do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, &created)
+	expectStatus(t, resp, http.StatusCreated)
+	if created.ID != 1 || created.Name != "Ursula" || created.CreatedAt.IsZero() {
+		t.Fatalf("created = %+v", created)
+	}
+	do(t, srv, http.MethodPost, "/authors/", `{"name":"Octavia"}`, nil)
+
+	var list []models.Author
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors", "", &list), http.StatusOK)
+	if len(list) != 2 || list[0].Name != "Ursula" || list[1].Name != "Octavia" {
+		t.Fatalf("list = %+v", list)
+	}
+
+	list = nil
+	do(t, srv, http.MethodGet, "/authors/?limit=1&offset=1", "", &list)
+	if len(list) != 1 || list[0].Name != "Octavia" {
+		t.Fatalf("paged list = %+v", list)
+	}
+
+	var got models.Author
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1", "", &got), http.StatusOK)
+	if got != created {
+		t.Fatalf("got %+v, want %+v", got, created)
+	}
+
+	var updated models.Author
+	expectStatus(t, do(t, srv, http.MethodPut, "/authors/1", `{"name":"Ursula K."}`, &updated), http.StatusOK)
+	if updated.Name != "Ursula K." || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("updated = %+v", updated)
+	}
+	do(t, srv, http.MethodGet, "/authors/1", "", &got)
+	if got.Name != "Ursula K." {
+		t.Fatalf("after update got %+v", got)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodDelete, "/authors/1", "", nil), http.StatusNoContent)
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1", "", nil), http.StatusNotFound)
+}
+
+func TestBooks(t *testing.T) {
+	srv := newTestServer(t)
+
+	var created models.Book
+	resp := do(t, srv, http.MethodPost, "/books", `{"title":"Dune","author_id":7}`, &created)
+	expectStatus(t, resp, http.StatusCreated)
+	if created.ID != 1 || created.Title != "Dune" || created.AuthorID != 7 {
+		t.Fatalf("created = %+v", created)
+	}
+	do(t, srv, http.MethodPost, "/books/", `{"title":"Kindred","author_id":8}`, nil)
+
+	var list []models.Book
+	expectStatus(t, do(t, srv, http.MethodGet, "/books?limit=10", "", &list), http.StatusOK)
+	if len(list) != 2 || list[1].Title != "Kindred" {
+		t.Fatalf("list = %+v", list)
+	}
+
+	var updated models.Book
+	expectStatus(t, do(t, srv, http.MethodPut, "/books/1", `{"title":"Dune Messiah","author_id":9}`, &updated), http.StatusOK)
+	if updated.Title != "Dune Messiah" || updated.AuthorID != 9 {
+		t.Fatalf("updated = %+v", updated)
+	}
+
+	var got models.Book
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/1", "", &got), http.StatusOK)
+	if got != updated {
+		t.Fatalf("got %+v, want %+v", got, updated)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodDelete, "/books/1", "", nil), http.StatusNoContent)
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/1", "", nil), http.StatusNotFound)
+}
+
+func TestTags(t *testing.T) {
+	srv := newTestServer(t)
+
+	var created models.Tag
+	expectStatus(t, do(t, srv, http.MethodPost, "/tags", `{"name":"sf"}`, &created), http.StatusCreated)
+	if created.ID != 1 || created.Name != "sf" {
+		t.Fatalf("created = %+v", created)
+	}
+	do(t, srv, http.MethodPost, "/tags/", `{"name":"fantasy"}`, nil)
+
+	expectStatus(t, do(t, srv, http.MethodPost, "/tags", `{"name":"sf"}`, nil), http.StatusConflict)
+
+	var list []models.Tag
+	expectStatus(t, do(t, srv, http.MethodGet, "/tags", "", &list), http.StatusOK)
+	if len(list) != 2 || list[0].Name != "sf" || list[1].Name != "fantasy" {
+		t.Fatalf("list = %+v", list)
+	}
+
+	var got models.Tag
+	expectStatus(t, do(t, srv, http.MethodGet, "/tags/2", "", &got), http.StatusOK)
+	if got.Name != "fantasy" {
+		t.Fatalf("got %+v", got)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodDelete, "/tags/2", "", nil), http.StatusNoContent)
+	expectStatus(t, do(t, srv, http.MethodGet, "/tags/2", "", nil), http.StatusNotFound)
+	expectStatus(t, do(t, srv, http.MethodDelete, "/tags/2", "", nil), http.StatusNotFound)
+}
+
+func TestTagLinks(t *testing.T) {
+	for _, owner := range []string{"books", "authors"} {
+		t.Run(owner, func(t *testing.T) {
+			srv := newTestServer(t)
+			do(t, srv, http.MethodPost, "/tags", `{"name":"sf"}`, nil)
+			do(t, srv, http.MethodPost, "/tags", `{"name":"classic"}`, nil)
+			base := "/" + owner + "/5/tags"
+
+			expectStatus(t, do(t, srv, http.MethodPost, base, `{"tag_id":2}`, nil), http.StatusCreated)
+			expectStatus(t, do(t, srv, http.MethodPost, base, `{"tag_id":1}`, nil), http.StatusCreated)
+			expectStatus(t, do(t, srv, http.MethodPost, base, `{"tag_id":1}`, nil), http.StatusConflict)
+
+			var tags []models.Tag
+			expectStatus(t, do(t, srv, http.MethodGet, base, "", &tags), http.StatusOK)
+			if len(tags) != 2 || tags[0].Name != "sf" || tags[1].Name != "classic" {
+				t.Fatalf("tags = %+v", tags)
+			}
+
+			expectStatus(t, do(t, srv, http.MethodDelete, base+"/1", "", nil), http.StatusNoContent)
+			expectStatus(t, do(t, srv, http.MethodDelete, base+"/1", "", nil), http.StatusNotFound)
+			expectStatus(t, do(t, srv, http.MethodDelete, base+"/x", "", nil), http.StatusBadRequest)
+			expectStatus(t, do(t, srv, http.MethodPut, base, `{"tag_id":1}`, nil), http.StatusMethodNotAllowed)
+			expectStatus(t, do(t, srv, http.MethodPost, base, `{`, nil), http.StatusBadRequest)
+
+			tags = nil
+			do(t, srv, http.MethodGet, base, "", &tags)
+			if len(tags) != 1 || tags[0].ID != 2 {
+				t.Fatalf("after remove tags = %+v", tags)
+			}
+		})
+	}
+}
+
+// countingStore counts per-row lookups, which ?expand= must not use.
+type countingStore struct {
+	store.Store
+	perRow, batched int
+}
+
+func (s *countingStore) GetAuthor(ctx context.Context, id int64) (models.Author, error) {
+	s.perRow++
+	return s.Store.GetAuthor(ctx, id)
+}
+
+func (s *countingStore) ListBookTags(ctx context.Context, bookID int64) ([]models.Tag, error) {
+	s.perRow++
+	return s.Store.ListBookTags(ctx, bookID)
+}
+
+func (s *countingStore) GetAuthorsByIDs(ctx context.Context, ids []int64) (map[int64]models.Author, error) {
+	s.batched++
+	return s.Store.GetAuthorsByIDs(ctx, ids)
+}
+
+func (s *countingStore) ListTagsByBooks(ctx context.Context, ids []int64) (map[int64][]models.Tag, error) {
+	s.batched++
+	return s.Store.ListTagsByBooks(ctx, ids)
+}
+
+func TestExpand(t *testing.T) {
+	srv := newTestServer(t)
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, nil)
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ted"}`, nil)
+	do(t, srv, http.MethodPost, "/books", `{"title":"A","author_id":1}`, nil)
+	do(t, srv, http.MethodPost, "/books", `{"title":"B","author_id":2}`, nil)
+	do(t, srv, http.MethodPost, "/books", `{"title":"C","author_id":1}`, nil)
+	do(t, srv, http.MethodPost, "/books", `{"title":"Orphan","author_id":99}`, nil)
+	do(t, srv, http.MethodPost, "/tags", `{"name":"sf"}`, nil)
+	do(t, srv, http.MethodPost, "/tags", `{"name":"classic"}`, nil)
+	do(t, srv, http.MethodPost, "/books/1/tags", `{"tag_id":2}`, nil)
+	do(t, srv, http.MethodPost, "/books/1/tags", `{"tag_id":1}`, nil)
+	do(t, srv, http.MethodPost, "/authors/2/tags", `{"tag_id":1}`, nil)
+
+	counter := &countingStore{Store: dataStore}
+	dataStore = counter
+
+	var books []models.BookDetail
+	expectStatus(t, do(t, srv, http.MethodGet, "/books?expand=author,tags", "", &books), http.StatusOK)
+	if len(books) != 4 {
+		t.Fatalf("got %d books", len(books))
+	}
+	if books[0].Author == nil || books[0].Author.Name != "Ursula" || len(books[0].Tags) != 2 || books[0].Tags[0].Name != "sf" {
+		t.Errorf("book 1 = %+v", books[0])
+	}
+	if books[1].Author == nil || books[1].Author.Name != "Ted" || len(books[1].Tags) != 0 {
+		t.Errorf("book 2 = %+v", books[1])
+	}
+	if books[3].Author != nil {
+		t.Errorf("orphan book has author %+v", books[3].Author)
+	}
+	if counter.perRow != 0 || counter.batched != 2 {
+		t.Errorf("per-row lookups = %d, batched = %d; want 0 and 2", counter.perRow, counter.batched)
+	}
+
+	var book models.BookDetail
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3?expand=author", "", &book), http.StatusOK)
+	if book.Author == nil || book.Author.ID != 1 || book.Tags != nil {
+		t.Errorf("book 3 = %+v", book)
+	}
+
+	var authors []models.AuthorDetail
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors?expand=books,tags", "", &authors), http.StatusOK)
+	if len(authors) != 2 || len(authors[0].Books) != 2 || authors[0].Books[1].Title != "C" || len(authors[1].Tags) != 1 {
+		t.Errorf("authors = %+v", authors)
+	}
+
+	prevLimit := ExpandBooksLimit
+	ExpandBooksLimit = 1
+	defer func() { ExpandBooksLimit = prevLimit }()
+	var author models.AuthorDetail
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1?expand=books", "", &author), http.StatusOK)
+	if len(author.Books) != 1 || author.Books[0].Title != "A" || !author.BooksTruncated {
+		t.Errorf("author 1 = %+v", author)
+	}
+	author = models.AuthorDetail{}
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1?expand=books&books_limit=2", "", &author), http.StatusOK)
+	if len(author.Books) != 2 || author.BooksTruncated {
+		t.Errorf("author 1 with books_limit=2 = %+v", author)
+	}
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1?expand=books&books_limit=0", "", nil), http.StatusBadRequest)
+
+	// Without expand the plain shape is unchanged
+	var raw map[string]interface{}
+	do(t, srv, http.MethodGet, "/books/1", "", &raw)
+	if _, ok := raw["author"]; ok {
+		t.Errorf("unexpanded book has an author: %v", raw)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodGet, "/books?expand=publisher", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/authors/1?expand=author", "", nil), http.StatusBadRequest)
+}
+
+func TestBookEvents(t *testing.T) {
+	srv := newTestServer(t)
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, nil)
+	for i := 0; i < 4; i++ {
+		do(t, srv, http.MethodPost, "/books", `{"title":"A","author_id":1}`, nil)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/3/events", `{"type":"view"}`, nil), http.StatusAccepted)
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/3/events", `{"type":"view"}`, nil), http.StatusAccepted)
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/3/events", `{"type":"purchase"}`, nil), http.StatusAccepted)
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/4/events", `{"type":"view"}`, nil), http.StatusAccepted)
+
+	var summary events.Summary
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3/events/summary", "", &summary), http.StatusOK)
+	if summary.BookID != 3 || summary.Views != 2 || summary.Purchases != 1 || len(summary.Daily) != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if today := time.Now().Format("2006-01-02"); summary.Daily[0].Date != today {
+		t.Fatalf("daily date = %s, want %s", summary.Daily[0].Date, today)
+	}
+
+	summary = events.Summary{}
+	do(t, srv, http.MethodGet, "/books/3/events/summary?from=2020-01-01&to=2020-02-01", "", &summary)
+	if summary.Views != 0 || len(summary.Daily) != 0 {
+		t.Fatalf("out of range summary = %+v", summary)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/99/events", `{"type":"view"}`, nil), http.StatusNotFound)
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/3/events", `{"type":"like"}`, nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodPost, "/books/3/events", `not json`, nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3/events/summary?from=yesterday", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3/events/summary?to=tomorrow", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3/events", "", nil), http.StatusMethodNotAllowed)
+	expectStatus(t, do(t, srv, http.MethodGet, "/books/3/events/other", "", nil), http.StatusNotFound)
+}
+
+func TestChanges(t *testing.T) {
+	srv := newTestServer(t)
+
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, nil)
+	do(t, srv, http.MethodPut, "/authors/1", `{"name":"Ursula K."}`, nil)
+	do(t, srv, http.MethodPost, "/books/1/tags", `{"tag_id":1}`, nil)
+	do(t, srv, http.MethodDelete, "/authors/1", "", nil)
+
+	var feed struct {
+		Events []outbox.Event `json:"events"`
+		Next   int64          `json:"next"`
+	}
+	expectStatus(t, do(t, srv, http.MethodGet, "/changes", "", &feed), http.StatusOK)
+	var types []string
+	for _, e := range feed.Events {
+		types = append(types, e.EventType)
+	}
+	if got := strings.Join(types, ","); got != "author.created,author.updated,book_tag.added,author.deleted" {
+		t.Fatalf("event types = %s", got)
+	}
+	if feed.Next != 4 {
+		t.Fatalf("next = %d", feed.Next)
+	}
+
+	feed.Events = nil
+	do(t, srv, http.MethodGet, "/changes?since=2&limit=1", "", &feed)
+	if len(feed.Events) != 1 || feed.Events[0].EventType != "book_tag.added" || feed.Next != 3 {
+		t.Fatalf("paged feed = %+v", feed)
+	}
+
+	feed.Events = nil
+	do(t, srv, http.MethodGet, "/changes?since=4", "", &feed)
+	if len(feed.Events) != 0 || feed.Next != 4 {
+		t.Fatalf("caught-up feed = %+v", feed)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodGet, "/changes?since=abc", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodPost, "/changes", "", nil), http.StatusMethodNotAllowed)
+}
+
+func TestAudit(t *testing.T) {
+	srv := newTestServer(t)
+
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, nil)
+	do(t, srv, http.MethodPut, "/authors/1", `{"name":"Ursula K."}`, nil)
+	do(t, srv, http.MethodPost, "/books", `{"title":"Dune","author_id":1}`, nil)
+
+	var entries []audit.Entry
+	expectStatus(t, do(t, srv, http.MethodGet, "/audit?entity=author&id=1", "", &entries), http.StatusOK)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	// Newest first
+	e := entries[0]
+	if e.Action != "update" || e.Actor != "tester" || e.RequestID == "" {
+		t.Fatalf("entry = %+v", e)
+	}
+	var before, after models.Author
+	json.Unmarshal(e.Before, &before)
+	json.Unmarshal(e.After, &after)
+	if before.Name != "Ursula" || after.Name != "Ursula K." {
+		t.Fatalf("before = %+v, after = %+v", before, after)
+	}
+	if string(entries[1].Before) != "null" {
+		t.Fatalf("create before = %s", entries[1].Before)
+	}
+
+	entries = nil
+	do(t, srv, http.MethodGet, "/audit?limit=1", "", &entries)
+	if len(entries) != 1 || entries[0].Entity != "book" {
+		t.Fatalf("limited entries = %+v", entries)
+	}
+
+	entries = nil
+	do(t, srv, http.MethodGet, "/audit?from=2020-01-01&to=2020-02-01", "", &entries)
+	if len(entries) != 0 {
+		t.Fatalf("out of range entries = %+v", entries)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodGet, "/audit?id=x", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/audit?from=x", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodGet, "/audit?to=x", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodDelete, "/audit", "", nil), http.StatusMethodNotAllowed)
+}
+
+func TestCacheStats(t *testing.T) {
+	srv := newTestServer(t)
+
+	do(t, srv, http.MethodPost, "/authors", `{"name":"Ursula"}`, nil)
+	do(t, srv, http.MethodGet, "/authors/1", "", nil)
+	do(t, srv, http.MethodGet, "/authors/2", "", nil)
+
+	var stats cache.Stats
+	expectStatus(t, do(t, srv, http.MethodGet, "/debug/cache", "", &stats), http.StatusOK)
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	expectStatus(t, do(t, srv, http.MethodPost, "/debug/cache", "", nil), http.StatusMethodNotAllowed)
+}
+
+func TestVariants(t *testing.T) {
+	srv := newTestServer(t)
+
+	var all []variantResponse
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants", "", &all), http.StatusOK)
+	if len(all) != len(variant.Default.All()) {
+		t.Fatalf("got %d variants, want %d", len(all), len(variant.Default.All()))
+	}
+
+	var hash []variantResponse
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants?group=hash", "", &hash), http.StatusOK)
+	for _, v := range hash {
+		if v.Group != "hash" || v.Teardown == "" {
+			t.Errorf("unexpected variant in hash group: %+v", v)
+		}
+	}
+
+	var one variantResponse
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants/books_range_year", "", &one), http.StatusOK)
+	if one.Method != variant.Range || one.Base != "books" || one.Partitions != 7 {
+		t.Errorf("books_range_year = %+v", one)
+	}
+
+	var base variantResponse
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants/books", "", &base), http.StatusOK)
+	if base.Teardown != "" {
+		t.Errorf("base table has a teardown: %q", base.Teardown)
+	}
+
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants/nope", "", nil), http.StatusNotFound)
+	expectStatus(t, do(t, srv, http.MethodGet, "/variants?group=nope", "", nil), http.StatusBadRequest)
+	expectStatus(t, do(t, srv, http.MethodPost, "/variants", "", nil), http.StatusMethodNotAllowed)
+}
+
+func TestMiscRoutes(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, http.MethodGet, "/health", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	var root struct {
+		Endpoints []string `json:"endpoints"`
+	}
+	expectStatus(t, do(t, srv, http.MethodGet, "/", "", &root), http.StatusOK)
+	if len(root.Endpoints) == 0 {
+		t.Fatal("root lists no endpoints")
+	}
+
+	expectStatus(t, do(t, srv, http.MethodGet, "/nope", "", nil), http.StatusNotFound)
+	// /ready inspects information_schema, so only its method check runs here
+	expectStatus(t, do(t, srv, http.MethodPost, "/ready", "", nil), http.StatusMethodNotAllowed)
+}
+
+func TestRequestID(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, http.MethodGet, "/tags", "", nil)
+	if len(resp.Header.Get("X-Request-ID")) != 32 {
+		t.Fatalf("generated X-Request-ID = %q", resp.Header.Get("X-Request-ID"))
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/tags", strings.NewReader(`{"name":"sf"}`))
+	req.Header.Set("X-Request-ID", "abc123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Request-ID") != "abc123" {
+		t.Fatalf("X-Request-ID = %q", resp.Header.Get("X-Request-ID"))
+	}
+
+	var entries []audit.Entry
+	do(t, srv, http.MethodGet, "/audit?entity=tag", "", &entries)
+	if len(entries) != 1 || entries[0].RequestID != "abc123" || entries[0].Actor != "anonymous" {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
+
+func TestWriteSetsLastWriteCookie(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, http.MethodPost, "/tags", `{"name":"sf"}`, nil)
+	var found bool
+	for _, c := range resp.Cookies() {
+		if c.Name == lastWriteCookie {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("no last_write cookie after a write")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPatch, "/authors", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/authors", "{", http.StatusBadRequest},
+		{http.MethodGet, "/authors/abc", "", http.StatusBadRequest},
+		{http.MethodGet, "/authors/99", "", http.StatusNotFound},
+		{http.MethodPut, "/authors/99", `{"name":"x"}`, http.StatusNotFound},
+		{http.MethodPut, "/authors/99", "{", http.StatusBadRequest},
+		{http.MethodDelete, "/authors/99", "", http.StatusNotFound},
+		{http.MethodPatch, "/authors/1", "", http.StatusMethodNotAllowed},
+
+		{http.MethodPatch, "/books", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/books", "{", http.StatusBadRequest},
+		{http.MethodGet, "/books/abc", "", http.StatusBadRequest},
+		{http.MethodGet, "/books/99", "", http.StatusNotFound},
+		{http.MethodPut, "/books/99", `{"title":"x"}`, http.StatusNotFound},
+		{http.MethodPut, "/books/99", "{", http.StatusBadRequest},
+		{http.MethodDelete, "/books/99", "", http.StatusNotFound},
+		{http.MethodPatch, "/books/1", "", http.StatusMethodNotAllowed},
+
+		{http.MethodPatch, "/tags", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/tags", "{", http.StatusBadRequest},
+		{http.MethodGet, "/tags/abc", "", http.StatusBadRequest},
+		{http.MethodGet, "/tags/99", "", http.StatusNotFound},
+		{http.MethodPut, "/tags/1", `{"name":"x"}`, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		resp := do(t, srv, tt.method, tt.path, tt.body, nil)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/migrate"
+	"github.com/sters/try-mysql-partitioning/migrations"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+// ReadyHorizonDays is how far ahead time-based RANGE tables must have
+// partitions for /ready to succeed.
+var ReadyHorizonDays = 7
+
+// expectedTables are the tables migrations create: the base and managed
+// variants plus the migration bookkeeping table.
+func expectedTables() []string {
+	var tables []string
+	for _, v := range variant.Default.All() {
+		if v.Group == variant.GroupBase || v.Group == variant.GroupManaged {
+			tables = append(tables, v.Table)
+		}
+	}
+	return append(tables, "schema_migrations")
+}
+
+type readyCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type readyResponse struct {
+	Ready  bool         `json:"ready"`
+	Checks []readyCheck `json:"checks"`
+}
+
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var checks []readyCheck
+	checks = append(checks, checkTables()...)
+	checks = append(checks, checkMigrations())
+	checks = append(checks, checkPartitionHorizon(time.Now().AddDate(0, 0, ReadyHorizonDays))...)
+
+	resp := readyResponse{Ready: true, Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			resp.Ready = false
+			break
+		}
+	}
+
+	if !resp.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	respondJSON(w, resp)
+}
+
+func checkTables() []readyCheck {
+	existing := make(map[string]bool)
+	rows, err := db.DB.Query("SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()")
+	if err != nil {
+		return []readyCheck{{Name: "tables", OK: false, Message: err.Error()}}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return []readyCheck{{Name: "tables", OK: false, Message: err.Error()}}
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return []readyCheck{{Name: "tables", OK: false, Message: err.Error()}}
+	}
+
+	tables := expectedTables()
+	checks := make([]readyCheck, 0, len(tables))
+	for _, table := range tables {
+		c := readyCheck{Name: "table:" + table, OK: existing[table]}
+		if !c.OK {
+			c.Message = "table does not exist"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// checkMigrations verifies that every migration embedded in this binary has
+// been applied, unmodified.
+func checkMigrations() readyCheck {
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return readyCheck{Name: "migrations", OK: false, Message: err.Error()}
+	}
+	applied, err := migrate.LoadApplied(context.Background(), db.DB)
+	if err != nil {
+		return readyCheck{Name: "migrations", OK: false, Message: err.Error()}
+	}
+
+	var pending, modified []string
+	for _, m := range all {
+		a, ok := applied[m.Version]
+		switch {
+		case !ok:
+			pending = append(pending, fmt.Sprintf("%d_%s", m.Version, m.Name))
+		case a.Checksum != m.Checksum:
+			modified = append(modified, fmt.Sprintf("%d_%s", m.Version, m.Name))
+		}
+	}
+
+	latest := migrate.Latest(all)
+	switch {
+	case len(pending) > 0:
+		return readyCheck{Name: "migrations", OK: false, Message: "pending: " + strings.Join(pending, ", ")}
+	case len(modified) > 0:
+		return readyCheck{Name: "migrations", OK: false, Message: "modified after apply: " + strings.Join(modified, ", ")}
+	}
+	return readyCheck{Name: "migrations", OK: true, Message: fmt.Sprintf("at version %d", latest)}
+}
+
+// checkPartitionHorizon verifies that every RANGE-partitioned table keyed on a
+// date/time column has a partition that accepts rows dated at horizon.
+func checkPartitionHorizon(horizon time.Time) []readyCheck {
+	rows, err := db.DB.Query(`
+		SELECT p.TABLE_NAME, p.PARTITION_NAME, p.PARTITION_EXPRESSION, p.PARTITION_DESCRIPTION
+		FROM information_schema.PARTITIONS p
+		WHERE p.TABLE_SCHEMA = DATABASE()
+		  AND p.PARTITION_METHOD IN ('RANGE', 'RANGE COLUMNS')
+		  AND p.PARTITION_ORDINAL_POSITION = (
+			SELECT MAX(p2.PARTITION_ORDINAL_POSITION) FROM information_schema.PARTITIONS p2
+			WHERE p2.TABLE_SCHEMA = p.TABLE_SCHEMA AND p2.TABLE_NAME = p.TABLE_NAME
+		  )
+		ORDER BY p.TABLE_NAME
+	`)
+	if err != nil {
+		return []readyCheck{{Name: "partitions", OK: false, Message: err.Error()}}
+	}
+
+	type lastPartition struct {
+		table, name, expr, desc string
+	}
+	var parts []lastPartition
+	for rows.Next() {
+		var p lastPartition
+		if err := rows.Scan(&p.table, &p.name, &p.expr, &p.desc); err != nil {
+			rows.Close()
+			return []readyCheck{{Name: "partitions", OK: false, Message: err.Error()}}
+		}
+		parts = append(parts, p)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return []readyCheck{{Name: "partitions", OK: false, Message: err.Error()}}
+	}
+
+	var checks []readyCheck
+	for _, p := range parts {
+		column, err := temporalColumn(p.table, p.expr)
+		if err != nil {
+			checks = append(checks, readyCheck{Name: "partitions:" + p.table, OK: false, Message: err.Error()})
+			continue
+		}
+		if column == "" {
+			continue // Not time-based
+		}
+
+		c := readyCheck{Name: "partitions:" + p.table}
+		if p.desc == "MAXVALUE" {
+			c.OK = true
+			c.Message = fmt.Sprintf("%s is unbounded (MAXVALUE)", p.name)
+			checks = append(checks, c)
+			continue
+		}
+
+		// Let MySQL evaluate the partition expression for the horizon date,
+		// binding it once for every use of the column
+		quoted := "`" + column + "`"
+		expr := strings.ReplaceAll(p.expr, quoted, "?")
+		args := make([]interface{}, strings.Count(p.expr, quoted))
+		for i := range args {
+			args[i] = horizon
+		}
+		var covered bool
+		err = db.DB.QueryRow("SELECT ("+expr+") < ("+p.desc+")", args...).Scan(&covered)
+		if err != nil {
+			c.Message = err.Error()
+		} else {
+			c.OK = covered
+			c.Message = fmt.Sprintf("last partition %s (LESS THAN %s), horizon %s",
+				p.name, p.desc, horizon.Format("2006-01-02"))
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// temporalColumn returns the date/time column referenced by a partition
+// expression, or "" if the expression does not use one.
+func temporalColumn(table, expr string) (string, error) {
+	rows, err := db.DB.Query(`
+		SELECT COLUMN_NAME FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+		  AND DATA_TYPE IN ('date', 'datetime', 'timestamp')
+	`, table)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		if strings.Contains(expr, "`"+name+"`") {
+			return name, nil
+		}
+	}
+	return "", rows.Err()
+}
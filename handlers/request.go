@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/sters/try-mysql-partitioning/store"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID gives every request an ID, taken from X-Request-ID when the
+// client sends one, and echoes it back in the response.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// actor identifies who made the request. There is no authentication, so the
+// client names itself with X-Actor.
+func actor(r *http.Request) string {
+	if a := r.Header.Get("X-Actor"); a != "" {
+		return a
+	}
+	return "anonymous"
+}
+
+// change describes the request's writes for the audit log.
+func change(r *http.Request) store.Change {
+	return store.Change{Actor: actor(r), RequestID: requestID(r)}
+}
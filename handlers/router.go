@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// NewRouter returns the API with all routes registered and request IDs
+// assigned.
+func NewRouter() http.Handler {
+	mux := http.NewServeMux()
+
+	// Authors routes
+	mux.HandleFunc("/authors", AuthorsHandler)
+	mux.HandleFunc("/authors/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/authors/" {
+			AuthorsHandler(w, r)
+			return
+		}
+		AuthorHandler(w, r)
+	})
+
+	// Books routes
+	mux.HandleFunc("/books", BooksHandler)
+	mux.HandleFunc("/books/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/books/" {
+			BooksHandler(w, r)
+			return
+		}
+		BookHandler(w, r)
+	})
+
+	// Tags routes
+	mux.HandleFunc("/tags", TagsHandler)
+	mux.HandleFunc("/tags/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tags/" {
+			TagsHandler(w, r)
+			return
+		}
+		TagHandler(w, r)
+	})
+
+	// Health check
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if err := dataStore.Ping(r.Context()); err != nil {
+			http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("OK"))
+	})
+
+	// Change feed
+	mux.HandleFunc("/changes", ChangesHandler)
+
+	// Audit log
+	mux.HandleFunc("/audit", AuditHandler)
+
+	// Readiness check (schema and partition layout)
+	mux.HandleFunc("/ready", ReadyHandler)
+
+	// Table variants (base, partitioned copies, partman-managed)
+	mux.HandleFunc("/variants", VariantsHandler)
+	mux.HandleFunc("/variants/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/variants/" {
+			VariantsHandler(w, r)
+			return
+		}
+		VariantHandler(w, r)
+	})
+
+	// Cache hit/miss counters
+	mux.HandleFunc("/debug/cache", CacheStatsHandler)
+
+	// Deadlock / lock wait timeout retry counters
+	mux.HandleFunc("/debug/retries", RetryStatsHandler)
+
+	// Root
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"message":"MySQL Partitioning Experiment API","endpoints":["/authors","/books","/tags","/changes","/audit","/variants","/health","/ready"]}`))
+	})
+
+	return WithRequestID(mux)
+}
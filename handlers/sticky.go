@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+)
+
+const lastWriteCookie = "last_write"
+
+// readContext returns the request context carrying the client's last write
+// time, so db.Reader keeps its reads on the primary for a short while.
+func readContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if c, err := r.Cookie(lastWriteCookie); err == nil {
+		if ns, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+			ctx = db.WithLastWrite(ctx, time.Unix(0, ns))
+		}
+	}
+	return ctx
+}
+
+// markWrite tells the client, via cookie, that it just wrote, so its next
+// reads stay on the primary.
+func markWrite(w http.ResponseWriter) {
+	if db.StickyWindow <= 0 {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     lastWriteCookie,
+		Value:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		Path:     "/",
+		MaxAge:   int(db.StickyWindow/time.Second) + 1,
+		HttpOnly: true,
+	})
+}
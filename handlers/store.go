@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/store"
+)
+
+var dataStore store.Store = store.NewCached(store.NewMySQL(), cache.NewLRU(10000, time.Minute))
+
+// SetStore replaces the store behind all handlers.
+func SetStore(s store.Store) {
+	dataStore = s
+}
+
+// storeError writes the response for an error returned by the store.
+// notFound is the message used for store.ErrNotFound.
+func storeError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, store.ErrDuplicate):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
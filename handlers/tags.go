@@ -1,14 +1,10 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
-
-	"github.com/sters/try-mysql-partitioning/db"
-	"github.com/sters/try-mysql-partitioning/models"
 )
 
 func TagsHandler(w http.ResponseWriter, r *http.Request) {
@@ -40,36 +36,19 @@ func TagHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func listTags(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.DB.Query("SELECT id, name FROM tags ORDER BY id")
+	tags, err := dataStore.ListTags(readContext(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var tags []models.Tag
-	for rows.Next() {
-		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		tags = append(tags, t)
-	}
 
 	respondJSON(w, tags)
 }
 
 func getTag(w http.ResponseWriter, r *http.Request, id int64) {
-	var t models.Tag
-	err := db.DB.QueryRow("SELECT id, name FROM tags WHERE id = ?", id).
-		Scan(&t.ID, &t.Name)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Tag not found", http.StatusNotFound)
-		return
-	}
+	t, err := dataStore.GetTag(readContext(r), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Tag not found")
 		return
 	}
 
@@ -85,34 +64,24 @@ func createTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := db.DB.Exec("INSERT INTO tags (name) VALUES (?)", input.Name)
+	t, err := dataStore.CreateTag(r.Context(), change(r), input.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		storeError(w, err, "Tag not found")
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	var t models.Tag
-	db.DB.QueryRow("SELECT id, name FROM tags WHERE id = ?", id).
-		Scan(&t.ID, &t.Name)
-
+	markWrite(w)
 	w.WriteHeader(http.StatusCreated)
 	respondJSON(w, t)
 }
 
 func deleteTag(w http.ResponseWriter, r *http.Request, id int64) {
-	result, err := db.DB.Exec("DELETE FROM tags WHERE id = ?", id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Tag not found", http.StatusNotFound)
+	if err := dataStore.DeleteTag(r.Context(), change(r), id); err != nil {
+		storeError(w, err, "Tag not found")
 		return
 	}
 
+	markWrite(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/variant"
+)
+
+type variantResponse struct {
+	variant.Variant
+	Teardown string        `json:"teardown,omitempty"`
+	Stats    *variantStats `json:"stats,omitempty"`
+}
+
+// variantStats is what information_schema currently says about a table.
+// Rows and sizes are InnoDB estimates.
+type variantStats struct {
+	Exists     bool  `json:"exists"`
+	Partitions int   `json:"partitions"`
+	Rows       int64 `json:"rows_estimate"`
+	DataBytes  int64 `json:"data_bytes"`
+	IndexBytes int64 `json:"index_bytes"`
+}
+
+// VariantsHandler lists the registered table variants. ?group= filters by
+// group and ?stats=true adds live information from information_schema.
+func VariantsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	variants := variant.Default.All()
+	if group := r.URL.Query().Get("group"); group != "" {
+		if group != variant.GroupBase && group != variant.GroupManaged && !variant.Default.HasGroup(group) {
+			http.Error(w, "Unknown group", http.StatusBadRequest)
+			return
+		}
+		variants = variant.Default.Group(group)
+	}
+
+	resp, err := variantResponses(r, variants)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, resp)
+}
+
+// VariantHandler returns one variant by table name.
+func VariantHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	v, ok := variant.Default.Get(strings.TrimPrefix(r.URL.Path, "/variants/"))
+	if !ok {
+		http.Error(w, "Variant not found", http.StatusNotFound)
+		return
+	}
+
+	resp, err := variantResponses(r, []variant.Variant{v})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, resp[0])
+}
+
+func variantResponses(r *http.Request, variants []variant.Variant) ([]variantResponse, error) {
+	resp := make([]variantResponse, len(variants))
+	for i, v := range variants {
+		resp[i] = variantResponse{Variant: v, Teardown: v.Teardown()}
+	}
+
+	if withStats, _ := strconv.ParseBool(r.URL.Query().Get("stats")); !withStats {
+		return resp, nil
+	}
+
+	stats, err := loadVariantStats(r)
+	if err != nil {
+		return nil, err
+	}
+	for i := range resp {
+		s := stats[resp[i].Table]
+		resp[i].Stats = &s
+	}
+	return resp, nil
+}
+
+func loadVariantStats(r *http.Request) (map[string]variantStats, error) {
+	// Unpartitioned tables have a single row with a NULL PARTITION_NAME
+	rows, err := db.DB.QueryContext(r.Context(), `
+		SELECT TABLE_NAME, COUNT(PARTITION_NAME),
+		       COALESCE(SUM(TABLE_ROWS), 0), COALESCE(SUM(DATA_LENGTH), 0), COALESCE(SUM(INDEX_LENGTH), 0)
+		FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE()
+		GROUP BY TABLE_NAME
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]variantStats)
+	for rows.Next() {
+		var table string
+		s := variantStats{Exists: true}
+		if err := rows.Scan(&table, &s.Partitions, &s.Rows, &s.DataBytes, &s.IndexBytes); err != nil {
+			return nil, err
+		}
+		stats[table] = s
+	}
+	return stats, rows.Err()
+}
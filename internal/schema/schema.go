@@ -0,0 +1,45 @@
+// Package schema holds the table lookups and counts the cmd tools share.
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Quote quotes an identifier with backticks.
+func Quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// TableExists reports whether table exists in the current schema.
+func TableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+	`, table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("look up %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// CountTable returns the exact number of rows in table.
+func CountTable(db *sql.DB, table string) (int64, error) {
+	var count int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + Quote(table)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count %s: %w", table, err)
+	}
+	return count, nil
+}
+
+// CountPartition returns the exact number of rows in one partition of table.
+func CountPartition(db *sql.DB, table, partition string) (int64, error) {
+	var count int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s PARTITION (%s)", Quote(table), Quote(partition))
+	if err := db.QueryRow(query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count %s PARTITION (%s): %w", table, partition, err)
+	}
+	return count, nil
+}
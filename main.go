@@ -1,83 +1,165 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/config"
 	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/events"
 	"github.com/sters/try-mysql-partitioning/handlers"
+	"github.com/sters/try-mysql-partitioning/migrate"
+	"github.com/sters/try-mysql-partitioning/migrations"
+	"github.com/sters/try-mysql-partitioning/outbox"
+	"github.com/sters/try-mysql-partitioning/partman"
+	"github.com/sters/try-mysql-partitioning/store"
 )
 
 func main() {
-	if err := db.Init(); err != nil {
+	dbFlags := config.RegisterFlags(flag.CommandLine, config.Default())
+	flag.Parse()
+
+	if err := db.Init(dbFlags.MustLoad()); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
-	mux := http.NewServeMux()
-
-	// Authors routes
-	mux.HandleFunc("/authors", handlers.AuthorsHandler)
-	mux.HandleFunc("/authors/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/authors/" {
-			handlers.AuthorsHandler(w, r)
-			return
+	// Bring the schema up to date before serving
+	if on, _ := strconv.ParseBool(os.Getenv("MIGRATE_ON_START")); on {
+		all, err := migrate.Load(migrations.FS)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
 		}
-		handlers.AuthorHandler(w, r)
-	})
-
-	// Books routes
-	mux.HandleFunc("/books", handlers.BooksHandler)
-	mux.HandleFunc("/books/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/books/" {
-			handlers.BooksHandler(w, r)
-			return
+		m := migrate.New(db.Writer(), all)
+		m.Log = log.Printf
+		if err := m.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
 		}
-		handlers.BookHandler(w, r)
-	})
+	}
 
-	// Tags routes
-	mux.HandleFunc("/tags", handlers.TagsHandler)
-	mux.HandleFunc("/tags/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/tags/" {
-			handlers.TagsHandler(w, r)
-			return
-		}
-		handlers.TagHandler(w, r)
-	})
+	if days, err := strconv.Atoi(os.Getenv("READY_HORIZON_DAYS")); err == nil && days >= 0 {
+		handlers.ReadyHorizonDays = days
+	}
 
-	// Health check
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		if err := db.DB.Ping(); err != nil {
-			http.Error(w, "Database unavailable", http.StatusServiceUnavailable)
-			return
-		}
-		w.Write([]byte("OK"))
-	})
+	// Entity cache: CACHE_SIZE=0 disables it, so every lookup hits MySQL
+	cacheSize := 10000
+	cacheTTL := time.Minute
+	if size, err := strconv.Atoi(os.Getenv("CACHE_SIZE")); err == nil && size >= 0 {
+		cacheSize = size
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_TTL")); err == nil {
+		cacheTTL = ttl
+	}
+	mysqlStore := store.NewMySQL()
+	var cached *store.Cached
+	if cacheSize == 0 {
+		cached = store.NewCached(mysqlStore, &cache.Noop{})
+		// Nothing is filled, so there is nothing to keep consistent
+		cached.FillFromReplica = true
+	} else {
+		cached = store.NewCached(mysqlStore, cache.NewLRU(cacheSize, cacheTTL))
+		cached.FillFromReplica, _ = strconv.ParseBool(os.Getenv("CACHE_FILL_FROM_REPLICA"))
+	}
+	handlers.SetStore(cached)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Root
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
+	// Keep future partitions in place and drop expired ones
+	outboxRetention := 7
+	if days, err := strconv.Atoi(os.Getenv("OUTBOX_RETENTION_DAYS")); err == nil && days >= 0 {
+		outboxRetention = days
+	}
+	auditRetention := 12
+	if months, err := strconv.Atoi(os.Getenv("AUDIT_RETENTION_MONTHS")); err == nil && months >= 0 {
+		auditRetention = months
+	}
+	eventRetention := 0
+	if days, err := strconv.Atoi(os.Getenv("BOOK_EVENTS_RETENTION_DAYS")); err == nil && days >= 0 {
+		eventRetention = days
+	}
+	partitions := []partman.Spec{
+		{Table: "outbox", Interval: partman.Day, Ahead: handlers.ReadyHorizonDays + 7, Retain: outboxRetention},
+		{Table: "audit_log", Interval: partman.Month, Ahead: 2, Retain: auditRetention},
+		{Table: "book_events", Interval: partman.Day, Ahead: handlers.ReadyHorizonDays + 7, Retain: eventRetention},
+	}
+	// Writes to these tables fail until today's partition exists, so the
+	// first pass must finish before serving
+	for _, spec := range partitions {
+		if err := partman.Maintain(ctx, db.Writer(), spec, time.Now()); err != nil {
+			log.Fatalf("Failed to maintain partitions: %v", err)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"message":"MySQL Partitioning Experiment API","endpoints":["/authors","/books","/tags","/health"]}`))
-	})
+	}
+	go partman.Run(ctx, db.Writer(), partitions, time.Hour)
+
+	// Hold change feed events back until concurrent transactions have committed
+	if window, err := time.ParseDuration(os.Getenv("OUTBOX_SETTLE_WINDOW")); err == nil && window >= 0 {
+		outbox.SettleWindow = window
+	}
+
+	// Deliver outbox events to a webhook
+	if url := os.Getenv("OUTBOX_WEBHOOK_URL"); url != "" {
+		go outbox.NewRelay(db.Writer(), url).Run(ctx)
+		log.Printf("Outbox relay delivering to %s", url)
+	}
+
+	// Batch book event inserts
+	eventBatch := 500
+	if n, err := strconv.Atoi(os.Getenv("EVENT_BATCH_SIZE")); err == nil && n > 0 {
+		eventBatch = n
+	}
+	eventMax := 100 * eventBatch
+	if n, err := strconv.Atoi(os.Getenv("EVENT_BUFFER_MAX")); err == nil && n > 0 {
+		eventMax = n
+	}
+	// Through the store, so batches get its deadlock retries
+	eventBuffer := events.NewBuffer(mysqlStore.AddBookEvents, eventBatch, eventMax, time.Second)
+	handlers.SetEventBuffer(eventBuffer)
 
 	// Simple logging middleware
-	handler := loggingMiddleware(mux)
+	handler := loggingMiddleware(handlers.NewRouter())
+
+	server := &http.Server{Addr: ":8080", Handler: handler}
+
+	// Shut down cleanly so buffered events are flushed. ListenAndServe
+	// returns as soon as Shutdown starts, so wait for the in-flight requests
+	// to finish adding their events before closing the buffer.
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+
+		log.Println("Shutting down...")
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelShutdown()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+	}()
 
 	log.Println("Server starting on :8080")
-	if err := http.ListenAndServe(":8080", handler); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
+
+	<-shutdownDone
+	eventBuffer.Close()
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.HasPrefix(r.URL.Path, "/health") {
+		if !strings.HasPrefix(r.URL.Path, "/health") && !strings.HasPrefix(r.URL.Path, "/ready") {
 			log.Printf("%s %s", r.Method, r.URL.Path)
 		}
 		next.ServeHTTP(w, r)
@@ -0,0 +1,362 @@
+// Package migrate applies versioned schema migrations and records them in
+// the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/sqlscript"
+)
+
+// lockName is the GET_LOCK name held while migrating, so that two servers
+// or a server and cmd/migrate never migrate at once.
+const lockName = "schema_migrations"
+
+// LockTimeout is how long to wait for another migration to finish.
+var LockTimeout = 30 * time.Second
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+	// UpFile and DownFile are the file names, for error positions.
+	UpFile   string
+	DownFile string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads migrations from fsys, ordered by version. Every version needs
+// both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		if version <= 0 {
+			return nil, fmt.Errorf("%s: version must be positive", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("version %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up, mig.UpFile = string(data), e.Name()
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down, mig.DownFile = string(data), e.Name()
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both .up.sql and .down.sql", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the highest version in migrations, or 0.
+func Latest(migrations []Migration) int64 {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Applied is a row of schema_migrations.
+type Applied struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status describes one migration, known from files, the database or both.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the file's checksum differs from the one
+	// recorded when it was applied.
+	Modified bool
+	// Missing is set when the database records a version with no file.
+	Missing bool
+}
+
+// Migrator runs migrations on a single connection, holding the migration
+// lock for the duration of each operation.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// Log, if set, is called before each migration runs.
+	Log func(format string, args ...interface{})
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Status lists every migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withConn(ctx, false, func(conn *sql.Conn) error {
+		applied, err := LoadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = status(m.migrations, applied)
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, Latest(m.migrations))
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withConn(ctx, true, func(conn *sql.Conn) error {
+		applied, err := m.verified(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := appliedVersions(applied)
+		if n > len(versions) {
+			n = len(versions)
+		}
+		for i := 0; i < n; i++ {
+			if err := m.down(ctx, conn, versions[len(versions)-1-i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until exactly the migrations up to version are
+// applied. To(ctx, 0) reverts everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown version %d", version)
+	}
+
+	return m.withConn(ctx, true, func(conn *sql.Conn) error {
+		applied, err := m.verified(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Revert newer migrations first, newest first
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i] > version {
+				if err := m.down(ctx, conn, versions[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.up(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) up(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	m.logf("Applying %d_%s", mig.Version, mig.Name)
+	if err := execScript(ctx, conn, mig.UpFile, mig.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)",
+		mig.Version, mig.Name, mig.Checksum)
+	return err
+}
+
+func (m *Migrator) down(ctx context.Context, conn *sql.Conn, version int64) error {
+	mig := m.find(version)
+	m.logf("Reverting %d_%s", mig.Version, mig.Name)
+	if err := execScript(ctx, conn, mig.DownFile, mig.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", version)
+	return err
+}
+
+// verified loads the applied migrations and refuses to continue if any of
+// them was edited or deleted after being applied.
+func (m *Migrator) verified(ctx context.Context, conn *sql.Conn) (map[int64]Applied, error) {
+	applied, err := LoadApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range status(m.migrations, applied) {
+		switch {
+		case s.Missing:
+			return nil, fmt.Errorf("migration %d_%s is applied but its file is missing", s.Version, s.Name)
+		case s.Modified:
+			return nil, fmt.Errorf("migration %d_%s was modified after it was applied (checksum mismatch)", s.Version, s.Name)
+		}
+	}
+	return applied, nil
+}
+
+// withConn runs fn on a dedicated connection after creating the
+// bookkeeping table. GET_LOCK is per session, hence the single connection.
+func (m *Migrator) withConn(ctx context.Context, lock bool, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if lock {
+		var got sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(LockTimeout/time.Second)).Scan(&got)
+		if err != nil {
+			return err
+		}
+		if got.Int64 != 1 {
+			return fmt.Errorf("another migration is running (could not get lock %q within %v)", lockName, LockTimeout)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) logf(format string, args ...interface{}) {
+	if m.Log != nil {
+		m.Log(format, args...)
+	}
+}
+
+// LoadApplied returns the rows of schema_migrations. It does not create
+// the table, so it fails on a database that was never migrated.
+func LoadApplied(ctx context.Context, q db.Queryer) (map[int64]Applied, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]Applied)
+	for rows.Next() {
+		var a Applied
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+func status(migrations []Migration, applied map[int64]Applied) []Status {
+	var statuses []Status
+	known := make(map[int64]bool)
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+			s.Modified = a.Checksum != mig.Checksum
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		if !known[a.Version] {
+			statuses = append(statuses, Status{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: a.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses
+}
+
+func appliedVersions(applied map[int64]Applied) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// execScript runs each statement of a migration in order. MySQL commits
+// DDL implicitly, so a failing migration may be partially applied; write
+// migrations with IF [NOT] EXISTS so they can be re-run.
+func execScript(ctx context.Context, conn *sql.Conn, name, script string) error {
+	stmts, err := sqlscript.Parse(name, script)
+	if err != nil {
+		return err
+	}
+	runner := &sqlscript.Runner{Conn: conn, StopOnError: true}
+	_, err = runner.Run(ctx, stmts)
+	return err
+}
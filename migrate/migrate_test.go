@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_books.up.sql":     {Data: []byte("CREATE TABLE books (id INT);")},
+		"0002_add_books.down.sql":   {Data: []byte("DROP TABLE books;")},
+		"0001_add_authors.up.sql":   {Data: []byte("CREATE TABLE authors (id INT);")},
+		"0001_add_authors.down.sql": {Data: []byte("DROP TABLE authors;")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("got %d migrations, want 2", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "add_authors" {
+		t.Errorf("first migration = %d_%s", migrations[0].Version, migrations[0].Name)
+	}
+	if migrations[1].Down != "DROP TABLE books;" {
+		t.Errorf("down = %q", migrations[1].Down)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("unexpected checksums %q %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+	if Latest(migrations) != 2 {
+		t.Errorf("Latest = %d, want 2", Latest(migrations))
+	}
+}
+
+func TestLoadRequiresDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_add_authors.up.sql": {Data: []byte("CREATE TABLE authors (id INT);")},
+	}
+	if _, err := Load(fsys); err == nil {
+		t.Fatal("expected an error for a missing down file")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "a", Checksum: "x"},
+		{Version: 2, Name: "b", Checksum: "y"},
+		{Version: 3, Name: "c", Checksum: "z"},
+	}
+	now := time.Now()
+	applied := map[int64]Applied{
+		1: {Version: 1, Name: "a", Checksum: "x", AppliedAt: now},
+		2: {Version: 2, Name: "b", Checksum: "changed", AppliedAt: now},
+		9: {Version: 9, Name: "gone", Checksum: "q", AppliedAt: now},
+	}
+
+	got := status(migrations, applied)
+	want := []Status{
+		{Version: 1, Name: "a", Applied: true, AppliedAt: now},
+		{Version: 2, Name: "b", Applied: true, AppliedAt: now, Modified: true},
+		{Version: 3, Name: "c"},
+		{Version: 9, Name: "gone", Applied: true, AppliedAt: now, Missing: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("status =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS author_tags;
+DROP TABLE IF EXISTS book_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS authors;
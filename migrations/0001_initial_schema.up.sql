@@ -1,4 +1,4 @@
--- MySQL Partitioning Experiment - Initial Schema (No Partitions)
+-- Catalog tables (no partitions)
 
 CREATE TABLE IF NOT EXISTS authors (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -0,0 +1,19 @@
+-- Transactional outbox for catalog change events
+-- created_at の日単位で RANGE パーティション。古いパーティションの DROP が保持期間の仕組み。
+-- 将来分のパーティションはアプリ起動時と 1 時間ごとに partman が追加する。
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT AUTO_INCREMENT,
+    aggregate_type VARCHAR(32) NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (id, created_at),
+    INDEX idx_undelivered (delivered_at, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+PARTITION BY RANGE (TO_DAYS(created_at)) (
+    PARTITION p_initial VALUES LESS THAN (TO_DAYS('2026-01-01'))
+);
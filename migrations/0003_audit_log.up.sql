@@ -0,0 +1,20 @@
+-- Audit log: 誰が何を変更したか
+-- created_at の月単位で RANGE パーティション。保持期間を過ぎた月のパーティションを DROP する。
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT AUTO_INCREMENT,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    entity VARCHAR(32) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    before_json JSON NULL,
+    after_json JSON NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, created_at),
+    INDEX idx_entity (entity, entity_id, created_at),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+PARTITION BY RANGE (TO_DAYS(created_at)) (
+    PARTITION p_initial VALUES LESS THAN (TO_DAYS('2026-01-01'))
+);
@@ -0,0 +1,14 @@
+-- Book view/purchase events: 追記専用の高ボリュームテーブル
+-- created_at の日単位で RANGE パーティション。将来分は partman、過去分は cmd/seed が追加する。
+
+CREATE TABLE IF NOT EXISTS book_events (
+    id BIGINT AUTO_INCREMENT,
+    book_id BIGINT NOT NULL,
+    event_type TINYINT NOT NULL COMMENT '1:view, 2:purchase',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, created_at),
+    INDEX idx_book_created (book_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+PARTITION BY RANGE (TO_DAYS(created_at)) (
+    PARTITION p_initial VALUES LESS THAN (TO_DAYS('2026-01-01'))
+);
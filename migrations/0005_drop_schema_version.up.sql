@@ -0,0 +1,4 @@
+-- schema_version は schema_migrations に置き換えられた
+-- 既存ボリュームでは mysql/init で作られたテーブルが残っているので削除する
+
+DROP TABLE IF EXISTS schema_version;
@@ -0,0 +1,12 @@
+// Package migrations holds the versioned schema migrations, embedded so the
+// server and cmd/migrate always carry the set they were built with.
+//
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql.
+// Applied migrations must not be edited: cmd/migrate verifies their
+// checksums.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -31,3 +31,26 @@ type AuthorTag struct {
 	TagID     int64     `json:"tag_id"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type BookEvent struct {
+	BookID    int64     `json:"book_id"`
+	Type      int       `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookDetail is a book with the relations requested through ?expand=.
+// Relations that were not requested, or are empty, are omitted.
+type BookDetail struct {
+	Book
+	Author *Author `json:"author,omitempty"`
+	Tags   []Tag   `json:"tags,omitempty"`
+}
+
+// AuthorDetail is an author with the relations requested through ?expand=.
+// BooksTruncated is set when the author has more books than were embedded.
+type AuthorDetail struct {
+	Author
+	Books          []Book `json:"books,omitempty"`
+	BooksTruncated bool   `json:"books_truncated,omitempty"`
+	Tags           []Tag  `json:"tags,omitempty"`
+}
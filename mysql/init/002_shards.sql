@@ -0,0 +1,9 @@
+-- Application-level sharding experiment
+-- books を author_id で分割して格納するシャード用スキーマ（cmd/compare -type shard）
+
+CREATE DATABASE IF NOT EXISTS bookdb_shard0 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE DATABASE IF NOT EXISTS bookdb_shard1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE DATABASE IF NOT EXISTS bookdb_shard2 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE DATABASE IF NOT EXISTS bookdb_shard3 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+GRANT ALL PRIVILEGES ON `bookdb\_shard%`.* TO 'app'@'%';
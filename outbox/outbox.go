@@ -0,0 +1,70 @@
+// Package outbox implements a transactional outbox: change events are written
+// to the outbox table in the same transaction as the entity change, and a
+// relay delivers them afterwards.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/db"
+)
+
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Enqueue records an event. Pass the transaction that performs the change so
+// the event is committed or rolled back together with it.
+func Enqueue(ctx context.Context, ex db.Execer, aggregateType string, aggregateID int64, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.ExecContext(ctx,
+		"INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload) VALUES (?, ?, ?, ?)",
+		aggregateType, aggregateID, eventType, data)
+	return err
+}
+
+// SettleWindow is how long an event must have been in the outbox before List
+// returns it. Ids are allocated at insert but become visible at commit, so a
+// transaction can commit after one with a higher id. A consumer that moved
+// past that higher id would never see the lower one. Holding events back
+// until they are older than the longest expected commit lag closes that gap;
+// a transaction that commits later than this can still be skipped.
+var SettleWindow = 5 * time.Second
+
+// List returns up to limit events with an ID greater than since, oldest
+// first, leaving out those younger than SettleWindow. The age is measured on
+// the database clock, which also sets created_at.
+func List(ctx context.Context, q db.Queryer, since int64, limit int) ([]Event, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, aggregate_type, aggregate_id, event_type, payload, created_at
+		FROM outbox WHERE id > ? AND created_at < NOW() - INTERVAL ? MICROSECOND
+		ORDER BY id LIMIT ?
+	`, since, SettleWindow.Microseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.EventType, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
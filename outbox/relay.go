@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Relay polls the outbox for undelivered events and POSTs each one as JSON
+// to a webhook. Events are delivered in ID order; a failing event blocks the
+// ones behind it until it succeeds or runs out of attempts.
+//
+// Only one relay should run against a database at a time.
+type Relay struct {
+	DB           *sql.DB
+	URL          string
+	Client       *http.Client
+	BatchSize    int
+	PollInterval time.Duration
+	// MaxAttempts is the total number of delivery attempts per event across
+	// polls. Events that exhaust it are skipped and stay undelivered.
+	MaxAttempts int
+	// Retries is the number of immediate retries within one poll, with
+	// jittered exponential backoff starting at RetryBackoff.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func NewRelay(db *sql.DB, url string) *Relay {
+	return &Relay{
+		DB:           db,
+		URL:          url,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		BatchSize:    100,
+		PollInterval: time.Second,
+		MaxAttempts:  10,
+		Retries:      3,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+// Run delivers events until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.deliverBatch(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("outbox relay: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) deliverBatch(ctx context.Context) (int, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, aggregate_type, aggregate_id, event_type, payload, created_at
+		FROM outbox WHERE delivered_at IS NULL AND attempts < ?
+		ORDER BY id LIMIT ?
+	`, r.MaxAttempts, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.AggregateType, &e.AggregateID, &e.EventType, &payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		e.Payload = payload
+		events = append(events, e)
+	}
+	rows.Close()
+
+	delivered := 0
+	for _, e := range events {
+		err := r.deliver(ctx, e)
+
+		if err != nil {
+			if _, uerr := r.DB.ExecContext(ctx,
+				"UPDATE outbox SET attempts = attempts + 1 WHERE id = ? AND created_at = ?",
+				e.ID, e.CreatedAt); uerr != nil {
+				return delivered, uerr
+			}
+			return delivered, fmt.Errorf("event %d: %w", e.ID, err)
+		}
+
+		if _, err := r.DB.ExecContext(ctx,
+			"UPDATE outbox SET delivered_at = ?, attempts = attempts + 1 WHERE id = ? AND created_at = ?",
+			time.Now(), e.ID, e.CreatedAt); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+func (r *Relay) deliver(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := r.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = r.post(ctx, body)
+		if err == nil || attempt >= r.Retries {
+			return err
+		}
+
+		// A zero RetryBackoff retries at once; rand.Int63n panics on 0
+		sleep := backoff / 2
+		if backoff > 0 {
+			sleep += time.Duration(rand.Int63n(int64(backoff)))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+		if backoff*2 > backoff {
+			backoff *= 2
+		}
+	}
+}
+
+func (r *Relay) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+// Package partman maintains RANGE partitions on tables partitioned by
+// TO_DAYS(created_at): it adds partitions ahead of time so inserts never fail
+// with "no partition for value", and drops expired ones as the retention
+// mechanism.
+package partman
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Interval int
+
+const (
+	Day Interval = iota
+	Month
+)
+
+// Spec describes one managed table.
+type Spec struct {
+	Table    string
+	Interval Interval
+	// Ahead is how many intervals past the current one must have partitions.
+	Ahead int
+	// Retain is how many past intervals to keep; 0 keeps everything.
+	Retain int
+}
+
+// toDaysEpoch is TO_DAYS('1970-01-01') in MySQL.
+const toDaysEpoch = 719528
+
+type partition struct {
+	name  string
+	bound int64 // TO_DAYS value of VALUES LESS THAN; -1 for MAXVALUE
+}
+
+func (iv Interval) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	if iv == Month {
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func (iv Interval) next(t time.Time) time.Time {
+	if iv == Month {
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func (iv Interval) prev(t time.Time) time.Time {
+	if iv == Month {
+		return t.AddDate(0, -1, 0)
+	}
+	return t.AddDate(0, 0, -1)
+}
+
+func (iv Interval) name(t time.Time) string {
+	if iv == Month {
+		return "p" + t.Format("200601")
+	}
+	return "p" + t.Format("20060102")
+}
+
+func toDays(t time.Time) int64 {
+	y, m, d := t.Date()
+	return int64(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix()/86400) + toDaysEpoch
+}
+
+func fromDays(n int64, loc *time.Location) time.Time {
+	u := time.Unix((n-toDaysEpoch)*86400, 0).UTC()
+	return time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, loc)
+}
+
+func listPartitions(ctx context.Context, db *sql.DB, table string) ([]partition, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT PARTITION_NAME, PARTITION_DESCRIPTION FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []partition
+	for rows.Next() {
+		var p partition
+		var desc string
+		if err := rows.Scan(&p.name, &desc); err != nil {
+			return nil, err
+		}
+		if desc == "MAXVALUE" {
+			p.bound = -1
+		} else if p.bound, err = strconv.ParseInt(desc, 10, 64); err != nil {
+			return nil, fmt.Errorf("%s.%s: unexpected partition bound %q", table, p.name, desc)
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 && rows.Err() == nil {
+		return nil, fmt.Errorf("%s is not partitioned", table)
+	}
+	return parts, rows.Err()
+}
+
+// EnsureFuture adds partitions to spec.Table until rows dated spec.Ahead
+// intervals from now have somewhere to go. A table whose newest partition is
+// in the past gets one catch-up partition for the gap first.
+func EnsureFuture(ctx context.Context, db *sql.DB, spec Spec, now time.Time) ([]string, error) {
+	iv := spec.Interval
+	horizon := iv.start(now)
+	for i := 0; i <= spec.Ahead; i++ {
+		horizon = iv.next(horizon)
+	}
+	return EnsureRange(ctx, db, spec.Table, iv, now, horizon)
+}
+
+// EnsureRange adds one partition per interval so that rows dated from
+// (inclusive) to to (exclusive) can be inserted. If the newest partition ends
+// before from, a single catch-up partition covers the gap.
+func EnsureRange(ctx context.Context, db *sql.DB, table string, iv Interval, from, to time.Time) ([]string, error) {
+	parts, err := listPartitions(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	last := parts[len(parts)-1]
+	if last.bound < 0 {
+		return nil, nil // MAXVALUE catches everything
+	}
+
+	from = iv.start(from)
+	var defs, names []string
+	bound := fromDays(last.bound, from.Location())
+	if bound.Before(from) {
+		name := iv.name(iv.prev(from))
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", name, toDays(from)))
+		names = append(names, name)
+		bound = from
+	}
+	for bound.Before(to) {
+		name := iv.name(bound)
+		bound = iv.next(iv.start(bound))
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", name, toDays(bound)))
+		names = append(names, name)
+	}
+
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE `%s` ADD PARTITION (%s)", table, strings.Join(defs, ", "))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// DropBefore drops every partition of table whose rows are all older than
+// cutoff. The partition holding cutoff itself is kept.
+func DropBefore(ctx context.Context, db *sql.DB, table string, cutoff time.Time) ([]string, error) {
+	parts, err := listPartitions(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := toDays(cutoff)
+	var names []string
+	for i, p := range parts {
+		// Never drop the last partition; a RANGE table needs at least one
+		if p.bound < 0 || p.bound > limit || i == len(parts)-1 {
+			break
+		}
+		names = append(names, p.name)
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE `%s` DROP PARTITION %s", table, strings.Join(names, ", "))
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Maintain applies one round of maintenance to spec.
+func Maintain(ctx context.Context, db *sql.DB, spec Spec, now time.Time) error {
+	added, err := EnsureFuture(ctx, db, spec, now)
+	if err != nil {
+		return fmt.Errorf("%s: add partitions: %w", spec.Table, err)
+	}
+	if len(added) > 0 {
+		log.Printf("partman: %s: added %s", spec.Table, strings.Join(added, ", "))
+	}
+
+	if spec.Retain > 0 {
+		cutoff := spec.Interval.start(now)
+		for i := 0; i < spec.Retain; i++ {
+			cutoff = spec.Interval.prev(cutoff)
+		}
+		dropped, err := DropBefore(ctx, db, spec.Table, cutoff)
+		if err != nil {
+			return fmt.Errorf("%s: drop partitions: %w", spec.Table, err)
+		}
+		if len(dropped) > 0 {
+			log.Printf("partman: %s: dropped %s", spec.Table, strings.Join(dropped, ", "))
+		}
+	}
+	return nil
+}
+
+// Run maintains specs every interval until ctx is done. Callers run the
+// first pass themselves with Maintain, so they can stop on its errors.
+func Run(ctx context.Context, db *sql.DB, specs []Spec, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, spec := range specs {
+			if err := Maintain(ctx, db, spec, time.Now()); err != nil {
+				log.Printf("partman: %v", err)
+			}
+		}
+	}
+}
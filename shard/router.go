@@ -0,0 +1,107 @@
+package shard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/sters/try-mysql-partitioning/config"
+)
+
+// Router sends queries for books to the shard that owns their author_id, and
+// fans out queries that do not carry the shard key.
+type Router struct {
+	Shards   []*sql.DB
+	Strategy Strategy
+}
+
+// Open connects to one schema per shard.
+func Open(shards []config.DB, strategy Strategy) (*Router, error) {
+	r := &Router{Strategy: strategy}
+	for i, cfg := range shards {
+		db, err := cfg.Open()
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			r.Close()
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		r.Shards = append(r.Shards, db)
+	}
+	return r, nil
+}
+
+func (r *Router) Close() {
+	for _, db := range r.Shards {
+		db.Close()
+	}
+}
+
+// ForAuthor returns the shard holding books by authorID.
+func (r *Router) ForAuthor(authorID int64) *sql.DB {
+	return r.Shards[r.Strategy.Shard(authorID)]
+}
+
+// QueryAll runs query on every shard in parallel (scatter) and hands each
+// shard's rows to fn. Calls to fn run concurrently, so fn should read into a
+// result of the shard's own, e.g. a slice indexed by shard, which the caller
+// merges after QueryAll returns (gather). The first error cancels the
+// queries still running on the other shards.
+func (r *Router) QueryAll(ctx context.Context, query string, args []interface{}, fn func(shard int, rows *sql.Rows) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, db := range r.Shards {
+		wg.Add(1)
+		go func(i int, db *sql.DB) {
+			defer wg.Done()
+
+			rows, err := db.QueryContext(ctx, query, args...)
+			if err == nil {
+				err = fn(i, rows)
+				rows.Close()
+			}
+
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("shard %d: %w", i, err)
+					cancel()
+				})
+			}
+		}(i, db)
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// SumAll runs a single-value aggregate (e.g. COUNT(*)) on every shard and
+// returns the sum.
+func (r *Router) SumAll(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	totals := make([]int64, len(r.Shards))
+	err := r.QueryAll(ctx, query, args, func(i int, rows *sql.Rows) error {
+		for rows.Next() {
+			var n sql.NullInt64
+			if err := rows.Scan(&n); err != nil {
+				return err
+			}
+			totals[i] += n.Int64
+		}
+		return rows.Err()
+	})
+
+	var total int64
+	for _, n := range totals {
+		total += n
+	}
+	return total, err
+}
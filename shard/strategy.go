@@ -0,0 +1,91 @@
+package shard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Strategy maps an author_id to a shard index.
+type Strategy interface {
+	Shard(authorID int64) int
+	// Predicate returns a SQL condition on column that selects the rows
+	// belonging to shard i. It is used to split an existing table.
+	Predicate(i int, column string) string
+}
+
+// Hash spreads authors with MOD(author_id, N), the same function MySQL uses
+// for PARTITION BY HASH. Negative ids, which AUTO_INCREMENT never hands out,
+// are reduced as unsigned so they still map to a shard.
+type Hash struct {
+	N int
+}
+
+func (h Hash) Shard(authorID int64) int {
+	return int(uint64(authorID) % uint64(h.N))
+}
+
+func (h Hash) Predicate(i int, column string) string {
+	return fmt.Sprintf("MOD(%s, %d) = %d", column, h.N, i)
+}
+
+// Range assigns shard i to author_id < Bounds[i]; the last shard takes
+// everything at or above the final bound.
+type Range struct {
+	Bounds []int64
+}
+
+func (r Range) Shard(authorID int64) int {
+	for i, b := range r.Bounds {
+		if authorID < b {
+			return i
+		}
+	}
+	return len(r.Bounds)
+}
+
+func (r Range) Predicate(i int, column string) string {
+	var conds []string
+	if i > 0 {
+		conds = append(conds, fmt.Sprintf("%s >= %d", column, r.Bounds[i-1]))
+	}
+	if i < len(r.Bounds) {
+		conds = append(conds, fmt.Sprintf("%s < %d", column, r.Bounds[i]))
+	}
+	if len(conds) == 0 {
+		return "1 = 1"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// ParseStrategy parses "hash" or "range:B1,B2,..." for n shards. A range map
+// must have exactly n-1 bounds.
+func ParseStrategy(spec string, n int) (Strategy, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("shard count must be positive, got %d", n)
+	}
+
+	kind, args, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "hash":
+		return Hash{N: n}, nil
+	case "range":
+		var bounds []int64
+		for _, part := range strings.Split(args, ",") {
+			b, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range bound %q: %w", part, err)
+			}
+			if len(bounds) > 0 && b <= bounds[len(bounds)-1] {
+				return nil, fmt.Errorf("range bounds must be increasing: %s", args)
+			}
+			bounds = append(bounds, b)
+		}
+		if len(bounds) != n-1 {
+			return nil, fmt.Errorf("range map for %d shards needs %d bounds, got %d", n, n-1, len(bounds))
+		}
+		return Range{Bounds: bounds}, nil
+	default:
+		return nil, fmt.Errorf("unknown shard strategy: %s", spec)
+	}
+}
@@ -0,0 +1,26 @@
+package shard
+
+import "testing"
+
+func TestHashShard(t *testing.T) {
+	h := Hash{N: 4}
+	for id, want := range map[int64]int{0: 0, 5: 1, 7: 3} {
+		if got := h.Shard(id); got != want {
+			t.Errorf("Shard(%d) = %d, want %d", id, got, want)
+		}
+	}
+	for _, id := range []int64{-1, -5, -1 << 63} {
+		if got := h.Shard(id); got < 0 || got >= h.N {
+			t.Errorf("Shard(%d) = %d, out of range", id, got)
+		}
+	}
+}
+
+func TestRangeShard(t *testing.T) {
+	r := Range{Bounds: []int64{100, 200}}
+	for id, want := range map[int64]int{-1: 0, 99: 0, 100: 1, 199: 1, 200: 2, 1 << 40: 2} {
+		if got := r.Shard(id); got != want {
+			t.Errorf("Shard(%d) = %d, want %d", id, got, want)
+		}
+	}
+}
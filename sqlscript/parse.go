@@ -0,0 +1,246 @@
+// Package sqlscript splits SQL scripts into statements the way the mysql
+// client does and runs them one by one.
+//
+// The tokenizer understands quoted strings and identifiers, "--", "#" and
+// "/* */" comments, and the client-side DELIMITER command, so stored
+// procedure bodies and semicolons inside strings survive intact.
+package sqlscript
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Statement is one statement of a script, without its delimiter.
+type Statement struct {
+	SQL string
+	// File and Line locate the first character of the statement.
+	File string
+	Line int
+}
+
+func (s Statement) Pos() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// Summary is the statement collapsed onto one line and cut to n runes, for
+// progress output.
+func (s Statement) Summary(n int) string {
+	sum := []rune(strings.Join(strings.Fields(s.SQL), " "))
+	if len(sum) > n {
+		return string(sum[:n-3]) + "..."
+	}
+	return string(sum)
+}
+
+// ReturnsRows reports whether the statement is a query whose result set is
+// worth printing.
+func (s Statement) ReturnsRows() bool {
+	switch s.Keyword() {
+	case "SELECT", "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "WITH", "TABLE", "VALUES", "CHECKSUM", "ANALYZE", "CHECK", "OPTIMIZE":
+		return true
+	}
+	return false
+}
+
+// Keyword returns the first word of the statement in upper case, e.g.
+// "INSERT".
+func (s Statement) Keyword() string {
+	return strings.ToUpper(firstWord(s.SQL))
+}
+
+// ParseFile reads and parses the script at path.
+func ParseFile(path string) ([]Statement, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, string(content))
+}
+
+// Parse splits src into statements. name is used for positions and errors.
+// Comments are dropped, except for "/*! */" version comments and "/*+ */"
+// optimizer hints, which MySQL executes.
+func Parse(name, src string) ([]Statement, error) {
+	p := &parser{name: name, src: src, line: 1, delimiter: ";"}
+	return p.parse()
+}
+
+type parser struct {
+	name string
+	src  string
+	pos  int
+	line int
+
+	delimiter string
+	stmts     []Statement
+
+	cur       strings.Builder
+	startLine int // 0 while cur holds no SQL yet
+}
+
+func (p *parser) parse() ([]Statement, error) {
+	for p.pos < len(p.src) {
+		if p.startLine == 0 && p.atLineStart() && p.hasPrefixFold("DELIMITER") {
+			ok, err := p.readDelimiter()
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+		}
+
+		if strings.HasPrefix(p.src[p.pos:], p.delimiter) {
+			p.advance(len(p.delimiter))
+			p.flush()
+			continue
+		}
+
+		c := p.src[p.pos]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			if err := p.readQuoted(c); err != nil {
+				return nil, err
+			}
+		case c == '#' || p.isDashComment():
+			p.skipLine()
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			if err := p.readBlockComment(); err != nil {
+				return nil, err
+			}
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				p.mark()
+			}
+			if p.startLine != 0 {
+				p.cur.WriteByte(c)
+			}
+			p.advance(1)
+		}
+	}
+	p.flush()
+	return p.stmts, nil
+}
+
+// readDelimiter handles "DELIMITER xx", which must be alone on its line.
+// It reports false, consuming nothing, if the line starts with some other
+// word such as a column named delimiter_x.
+func (p *parser) readDelimiter() (bool, error) {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	fields := strings.Fields(p.src[p.pos : p.pos+end])
+	if !strings.EqualFold(fields[0], "DELIMITER") {
+		return false, nil
+	}
+	if len(fields) != 2 {
+		return false, fmt.Errorf("%s:%d: DELIMITER needs exactly one argument", p.name, p.line)
+	}
+	p.delimiter = fields[1]
+	p.advance(end)
+	return true, nil
+}
+
+// readQuoted copies a string or quoted identifier, honouring doubled quotes
+// and, outside backticks, backslash escapes.
+func (p *parser) readQuoted(q byte) error {
+	line := p.line
+	p.mark()
+	p.cur.WriteByte(q)
+	p.advance(1)
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\\' && q != '`' && p.pos+1 < len(p.src) {
+			p.cur.WriteString(p.src[p.pos : p.pos+2])
+			p.advance(2)
+			continue
+		}
+		p.cur.WriteByte(c)
+		p.advance(1)
+		if c == q {
+			if p.pos < len(p.src) && p.src[p.pos] == q {
+				p.cur.WriteByte(q)
+				p.advance(1)
+				continue
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s:%d: unterminated %c", p.name, line, q)
+}
+
+func (p *parser) readBlockComment() error {
+	line := p.line
+	end := strings.Index(p.src[p.pos+2:], "*/")
+	if end < 0 {
+		return fmt.Errorf("%s:%d: unterminated /* comment", p.name, line)
+	}
+	comment := p.src[p.pos : p.pos+2+end+2]
+	if strings.HasPrefix(comment, "/*!") || strings.HasPrefix(comment, "/*+") {
+		p.mark()
+		p.cur.WriteString(comment)
+	} else if p.startLine != 0 {
+		p.cur.WriteByte(' ')
+	}
+	p.advance(len(comment))
+	return nil
+}
+
+// isDashComment reports whether "--" at pos starts a comment. MySQL needs a
+// space or control character after it, so "1--1" stays arithmetic.
+func (p *parser) isDashComment() bool {
+	if !strings.HasPrefix(p.src[p.pos:], "--") {
+		return false
+	}
+	return p.pos+2 == len(p.src) || p.src[p.pos+2] <= ' '
+}
+
+func (p *parser) skipLine() {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	p.advance(end)
+}
+
+func (p *parser) atLineStart() bool {
+	i := strings.LastIndexByte(p.src[:p.pos], '\n')
+	return strings.TrimSpace(p.src[i+1:p.pos]) == ""
+}
+
+func (p *parser) hasPrefixFold(s string) bool {
+	return len(p.src)-p.pos >= len(s) && strings.EqualFold(p.src[p.pos:p.pos+len(s)], s)
+}
+
+func (p *parser) mark() {
+	if p.startLine == 0 {
+		p.startLine = p.line
+	}
+}
+
+func (p *parser) advance(n int) {
+	p.line += strings.Count(p.src[p.pos:p.pos+n], "\n")
+	p.pos += n
+}
+
+func (p *parser) flush() {
+	if sql := strings.TrimSpace(p.cur.String()); sql != "" {
+		p.stmts = append(p.stmts, Statement{SQL: sql, File: p.name, Line: p.startLine})
+	}
+	p.cur.Reset()
+	p.startLine = 0
+}
+
+func firstWord(sql string) string {
+	sql = strings.TrimLeft(sql, " \t\r\n(")
+	end := strings.IndexFunc(sql, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '(' || r == ';'
+	})
+	if end < 0 {
+		return sql
+	}
+	return sql[:end]
+}
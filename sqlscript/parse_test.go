@@ -0,0 +1,157 @@
+package sqlscript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sqls(stmts []Statement) []string {
+	out := make([]string, len(stmts))
+	for i, s := range stmts {
+		out[i] = s.SQL
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	src := `-- leading comment; with a semicolon
+# hash comment;
+SELECT 'a;b', "c;d", ` + "`e;f`" + `;
+SELECT 'it''s', 'back\'slash;';
+/* block ; comment */ SELECT 1 /* inline; */ + 1;
+SELECT /*+ NO_INDEX_MERGE(t) */ 1--1;
+/*!40101 SET NAMES utf8mb4 */;
+SELECT 2
+-- trailing comment block
+/* and another */`
+
+	stmts, err := Parse("test.sql", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"SELECT 'a;b', \"c;d\", `e;f`",
+		`SELECT 'it''s', 'back\'slash;'`,
+		"SELECT 1   + 1",
+		"SELECT /*+ NO_INDEX_MERGE(t) */ 1--1",
+		"/*!40101 SET NAMES utf8mb4 */",
+		"SELECT 2",
+	}
+	if got := sqls(stmts); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+
+	lines := []int{3, 4, 5, 6, 7, 8}
+	for i, s := range stmts {
+		if s.Line != lines[i] {
+			t.Errorf("statement %d at line %d, want %d", i, s.Line, lines[i])
+		}
+	}
+	if stmts[0].Pos() != "test.sql:3" {
+		t.Errorf("Pos = %q", stmts[0].Pos())
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	src := `DROP PROCEDURE IF EXISTS p;
+
+DELIMITER //
+CREATE PROCEDURE p()
+BEGIN
+    DECLARE s TEXT DEFAULT '';
+    SET s = CONCAT(s, ';');
+END //
+DELIMITER ;
+
+CALL p();
+SELECT delimiter_col FROM t;
+`
+	stmts, err := Parse("proc.sql", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DROP PROCEDURE IF EXISTS p",
+		"CREATE PROCEDURE p()\nBEGIN\n    DECLARE s TEXT DEFAULT '';\n    SET s = CONCAT(s, ';');\nEND",
+		"CALL p()",
+		"SELECT delimiter_col FROM t",
+	}
+	if got := sqls(stmts); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+	if stmts[1].Line != 4 || stmts[2].Line != 11 {
+		t.Errorf("lines = %d, %d; want 4, 11", stmts[1].Line, stmts[2].Line)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"SELECT 'unterminated",
+		"SELECT 1 /* unterminated",
+		"DELIMITER",
+	} {
+		if _, err := Parse("bad.sql", src); err == nil || !strings.HasPrefix(err.Error(), "bad.sql:1:") {
+			t.Errorf("Parse(%q) error = %v, want one at bad.sql:1", src, err)
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	for sql, want := range map[string]bool{
+		"SELECT 1":               true,
+		"(SELECT 1) UNION ALL 2": true,
+		"show profiles":          true,
+		"EXPLAIN SELECT 1":       true,
+		"INSERT INTO t SELECT 1": false,
+		"CALL p()":               false,
+	} {
+		if got := (Statement{SQL: sql}).ReturnsRows(); got != want {
+			t.Errorf("ReturnsRows(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
+
+// The repository's own scripts must parse; the procedure scripts broke the
+// old split-on-semicolon approach.
+func TestParseRepositoryScripts(t *testing.T) {
+	files, err := filepath.Glob("../scripts/partition/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no scripts found")
+	}
+	for _, file := range files {
+		stmts, err := ParseFile(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		for _, s := range stmts {
+			if strings.HasPrefix(strings.ToUpper(s.SQL), "DELIMITER") {
+				t.Errorf("%s: DELIMITER leaked into a statement", s.Pos())
+			}
+		}
+	}
+
+	content, err := os.ReadFile("../scripts/partition/range_many_partitions.sql")
+	if err != nil {
+		t.Skip(err)
+	}
+	stmts, _ := Parse("range_many_partitions.sql", string(content))
+	procs := 0
+	for _, s := range stmts {
+		if strings.HasPrefix(s.SQL, "CREATE PROCEDURE") {
+			procs++
+			if !strings.HasSuffix(s.SQL, "END") {
+				t.Errorf("%s: procedure body cut short: ...%q", s.Pos(), s.SQL[len(s.SQL)-20:])
+			}
+		}
+	}
+	if procs != 3 {
+		t.Errorf("found %d procedures, want 3", procs)
+	}
+}
@@ -0,0 +1,129 @@
+package sqlscript
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Conn is satisfied by *sql.DB, *sql.Conn and *sql.Tx. Scripts that use
+// session state (user variables, SET profiling, temporary tables) need a
+// *sql.Conn so every statement runs in the same session.
+type Conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Result is the outcome of one statement.
+type Result struct {
+	Statement
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// Error reports a failed statement with its position.
+type Error struct {
+	Statement
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Pos(), e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Runner executes statements in order.
+type Runner struct {
+	Conn Conn
+	// StopOnError stops at the first failing statement. Otherwise every
+	// statement runs and Run reports the first failure at the end.
+	StopOnError bool
+	// OnResult, if set, is called after each statement.
+	OnResult func(Result)
+	// OnRows, if set, receives the result set of statements for which
+	// Statement.ReturnsRows is true; they are run with QueryContext instead
+	// of ExecContext. OnRows must not close rows.
+	OnRows func(Statement, *sql.Rows) error
+}
+
+// RunFile parses and runs the script at path.
+func (r *Runner) RunFile(ctx context.Context, path string) ([]Result, error) {
+	stmts, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Run(ctx, stmts)
+}
+
+// Run executes stmts and returns a result for each statement attempted. The
+// error is an *Error for the first failing statement.
+func (r *Runner) Run(ctx context.Context, stmts []Statement) ([]Result, error) {
+	results := make([]Result, 0, len(stmts))
+	var first error
+	for _, stmt := range stmts {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		res := r.exec(ctx, stmt)
+		results = append(results, res)
+		if r.OnResult != nil {
+			r.OnResult(res)
+		}
+
+		if res.Err != nil && first == nil {
+			first = &Error{Statement: stmt, Err: res.Err}
+			if r.StopOnError {
+				break
+			}
+		}
+	}
+	return results, first
+}
+
+func (r *Runner) exec(ctx context.Context, stmt Statement) Result {
+	res := Result{Statement: stmt}
+	start := time.Now()
+
+	if r.OnRows != nil && stmt.ReturnsRows() {
+		rows, err := r.Conn.QueryContext(ctx, stmt.SQL)
+		if err != nil {
+			res.Err = err
+			res.Duration = time.Since(start)
+			return res
+		}
+		defer rows.Close()
+		if err := r.OnRows(stmt, rows); err != nil {
+			res.Err = err
+		} else {
+			res.Err = rows.Err()
+		}
+		res.Duration = time.Since(start)
+		return res
+	}
+
+	result, err := r.Conn.ExecContext(ctx, stmt.SQL)
+	res.Duration = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.RowsAffected, _ = result.RowsAffected()
+	return res
+}
+
+// Failed returns the results that have an error.
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
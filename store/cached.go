@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+// Cached serves single-entity lookups from a cache in front of another
+// Store. Writes go to the underlying store first and then refresh or
+// invalidate the cached entry.
+//
+// A miss is only cached if no write invalidated the key while it was
+// loading: each invalidation bumps the key's generation, and a fill taken at
+// an older generation is dropped. Misses are loaded from the primary unless
+// FillFromReplica is set; a lagging replica can return a row older than the
+// last invalidation, which then stays cached until it expires.
+type Cached struct {
+	Store
+	cache cache.Cache
+
+	// FillFromReplica loads misses through the normal read routing
+	FillFromReplica bool
+
+	mu sync.Mutex
+	// generations are striped by key hash; a collision only drops a fill
+	generations [256]uint64
+}
+
+func NewCached(s Store, c cache.Cache) *Cached {
+	return &Cached{Store: s, cache: c}
+}
+
+func stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % 256)
+}
+
+func (s *Cached) generation(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generations[stripe(key)]
+}
+
+// fill caches value unless key was invalidated since generation gen.
+func (s *Cached) fill(key string, gen uint64, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generations[stripe(key)] == gen {
+		s.cache.Set(key, value)
+	}
+}
+
+func (s *Cached) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generations[stripe(key)]++
+	s.cache.Delete(key)
+}
+
+// loadContext is the context misses are loaded with.
+func (s *Cached) loadContext(ctx context.Context) context.Context {
+	if s.FillFromReplica {
+		return ctx
+	}
+	return db.WithPrimary(ctx)
+}
+
+// get returns the cached value of key or loads it. A client that just wrote
+// (see db.Sticky) always reads through to the primary, so it sees its write
+// even if a fill raced it.
+func get[T any](ctx context.Context, s *Cached, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if !db.Sticky(ctx) {
+		if v, ok := s.cache.Get(key); ok {
+			return v.(T), nil
+		}
+	}
+	gen := s.generation(key)
+	v, err := load(s.loadContext(ctx))
+	if err != nil {
+		return v, err
+	}
+	s.fill(key, gen, v)
+	return v, nil
+}
+
+// CacheStats reports the cache's hit/miss counters.
+func (s *Cached) CacheStats() cache.Stats {
+	return s.cache.Stats()
+}
+
+func (s *Cached) GetAuthor(ctx context.Context, id int64) (models.Author, error) {
+	return get(ctx, s, authorKey(id), func(ctx context.Context) (models.Author, error) {
+		return s.Store.GetAuthor(ctx, id)
+	})
+}
+
+func (s *Cached) CreateAuthor(ctx context.Context, c Change, name string) (models.Author, error) {
+	a, err := s.Store.CreateAuthor(ctx, c, name)
+	if err == nil {
+		s.cache.Set(authorKey(a.ID), a)
+	}
+	return a, err
+}
+
+func (s *Cached) UpdateAuthor(ctx context.Context, c Change, id int64, name string) (models.Author, error) {
+	a, err := s.Store.UpdateAuthor(ctx, c, id, name)
+	s.invalidate(authorKey(id))
+	return a, err
+}
+
+func (s *Cached) DeleteAuthor(ctx context.Context, c Change, id int64) error {
+	err := s.Store.DeleteAuthor(ctx, c, id)
+	s.invalidate(authorKey(id))
+	return err
+}
+
+func (s *Cached) GetBook(ctx context.Context, id int64) (models.Book, error) {
+	return get(ctx, s, bookKey(id), func(ctx context.Context) (models.Book, error) {
+		return s.Store.GetBook(ctx, id)
+	})
+}
+
+func (s *Cached) CreateBook(ctx context.Context, c Change, title string, authorID int64) (models.Book, error) {
+	b, err := s.Store.CreateBook(ctx, c, title, authorID)
+	if err == nil {
+		s.cache.Set(bookKey(b.ID), b)
+	}
+	return b, err
+}
+
+func (s *Cached) UpdateBook(ctx context.Context, c Change, id int64, title string, authorID int64) (models.Book, error) {
+	b, err := s.Store.UpdateBook(ctx, c, id, title, authorID)
+	s.invalidate(bookKey(id))
+	return b, err
+}
+
+func (s *Cached) DeleteBook(ctx context.Context, c Change, id int64) error {
+	err := s.Store.DeleteBook(ctx, c, id)
+	s.invalidate(bookKey(id))
+	return err
+}
+
+func (s *Cached) GetTag(ctx context.Context, id int64) (models.Tag, error) {
+	return get(ctx, s, tagKey(id), func(ctx context.Context) (models.Tag, error) {
+		return s.Store.GetTag(ctx, id)
+	})
+}
+
+func (s *Cached) CreateTag(ctx context.Context, c Change, name string) (models.Tag, error) {
+	t, err := s.Store.CreateTag(ctx, c, name)
+	if err == nil {
+		s.cache.Set(tagKey(t.ID), t)
+	}
+	return t, err
+}
+
+func (s *Cached) DeleteTag(ctx context.Context, c Change, id int64) error {
+	err := s.Store.DeleteTag(ctx, c, id)
+	s.invalidate(tagKey(id))
+	return err
+}
+
+// GetAuthorsByIDs serves what it can from the cache and loads the rest in
+// one batch. Like get, it skips the cache for a client that just wrote.
+func (s *Cached) GetAuthorsByIDs(ctx context.Context, ids []int64) (map[int64]models.Author, error) {
+	authors := make(map[int64]models.Author, len(ids))
+	var missing []int64
+	sticky := db.Sticky(ctx)
+	for _, id := range ids {
+		if sticky {
+			missing = append(missing, id)
+		} else if v, ok := s.cache.Get(authorKey(id)); ok {
+			authors[id] = v.(models.Author)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return authors, nil
+	}
+
+	gens := make(map[int64]uint64, len(missing))
+	for _, id := range missing {
+		gens[id] = s.generation(authorKey(id))
+	}
+	loaded, err := s.Store.GetAuthorsByIDs(s.loadContext(ctx), missing)
+	if err != nil {
+		return nil, err
+	}
+	for id, a := range loaded {
+		s.fill(authorKey(id), gens[id], a)
+		authors[id] = a
+	}
+	return authors, nil
+}
+
+func authorKey(id int64) string {
+	return "author:" + strconv.FormatInt(id, 10)
+}
+
+func bookKey(id int64) string {
+	return "book:" + strconv.FormatInt(id, 10)
+}
+
+func tagKey(id int64) string {
+	return "tag:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/cache"
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+// racingStore runs during while a GetAuthor is between its read and the
+// cache fill.
+type racingStore struct {
+	Store
+	during func()
+}
+
+func (s *racingStore) GetAuthor(ctx context.Context, id int64) (models.Author, error) {
+	a, err := s.Store.GetAuthor(ctx, id)
+	if s.during != nil {
+		during := s.during
+		s.during = nil
+		during()
+	}
+	return a, err
+}
+
+func TestCachedDropsFillRacingAnUpdate(t *testing.T) {
+	ctx := context.Background()
+	rs := &racingStore{Store: NewMemory()}
+	c := NewCached(rs, cache.NewLRU(10, time.Minute))
+
+	a, err := rs.CreateAuthor(ctx, Change{}, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.during = func() {
+		if _, err := c.UpdateAuthor(ctx, Change{}, a.ID, "new"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, _ := c.GetAuthor(ctx, a.ID); got.Name != "old" {
+		t.Fatalf("racing read = %q, want the value read before the update", got.Name)
+	}
+	if got, _ := c.GetAuthor(ctx, a.ID); got.Name != "new" {
+		t.Errorf("after the update = %q, want new", got.Name)
+	}
+}
+
+func TestCachedSkipsCacheWhenSticky(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	c := NewCached(mem, cache.NewLRU(10, time.Minute))
+
+	a, err := c.CreateAuthor(ctx, Change{}, "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A change the cache did not see, e.g. a stale fill
+	if _, err := mem.UpdateAuthor(ctx, Change{}, a.ID, "primary"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := c.GetAuthor(ctx, a.ID); got.Name != "cached" {
+		t.Fatalf("without a recent write = %q, want the cached entry", got.Name)
+	}
+	sticky := db.WithLastWrite(ctx, time.Now())
+	if got, _ := c.GetAuthor(sticky, a.ID); got.Name != "primary" {
+		t.Errorf("after a write = %q, want the primary's row", got.Name)
+	}
+	if got, _ := c.GetAuthorsByIDs(sticky, []int64{a.ID}); got[a.ID].Name != "primary" {
+		t.Errorf("batch after a write = %q, want the primary's row", got[a.ID].Name)
+	}
+}
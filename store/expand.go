@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+
+	"github.com/sters/try-mysql-partitioning/models"
+)
+
+// BookExpand selects the relations ExpandBooks loads.
+type BookExpand struct {
+	Author bool
+	Tags   bool
+}
+
+// AuthorExpand selects the relations ExpandAuthors loads. BooksLimit caps
+// the books embedded per author, marking authors with more as truncated;
+// <= 0 embeds all of them.
+type AuthorExpand struct {
+	Books      bool
+	Tags       bool
+	BooksLimit int
+}
+
+// ExpandBooks attaches the requested relations to books with one query per
+// relation, however many books there are.
+func ExpandBooks(ctx context.Context, s RelationStore, books []models.Book, e BookExpand) ([]models.BookDetail, error) {
+	details := make([]models.BookDetail, len(books))
+	bookIDs := make([]int64, len(books))
+	authorIDs := make([]int64, len(books))
+	for i, b := range books {
+		details[i].Book = b
+		bookIDs[i] = b.ID
+		authorIDs[i] = b.AuthorID
+	}
+	if len(books) == 0 {
+		return details, nil
+	}
+
+	if e.Author {
+		authors, err := s.GetAuthorsByIDs(ctx, authorIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range details {
+			// No foreign keys: the author may be gone
+			if a, ok := authors[details[i].AuthorID]; ok {
+				details[i].Author = &a
+			}
+		}
+	}
+
+	if e.Tags {
+		tags, err := s.ListTagsByBooks(ctx, bookIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range details {
+			details[i].Tags = tags[details[i].ID]
+		}
+	}
+	return details, nil
+}
+
+// ExpandAuthors attaches the requested relations to authors with one query
+// per relation.
+func ExpandAuthors(ctx context.Context, s RelationStore, authors []models.Author, e AuthorExpand) ([]models.AuthorDetail, error) {
+	details := make([]models.AuthorDetail, len(authors))
+	ids := make([]int64, len(authors))
+	for i, a := range authors {
+		details[i].Author = a
+		ids[i] = a.ID
+	}
+	if len(authors) == 0 {
+		return details, nil
+	}
+
+	if e.Books {
+		// One more than the limit tells whether there are more
+		perAuthor := e.BooksLimit
+		if perAuthor > 0 {
+			perAuthor++
+		}
+		books, err := s.ListBooksByAuthors(ctx, ids, perAuthor)
+		if err != nil {
+			return nil, err
+		}
+		for i := range details {
+			bs := books[details[i].ID]
+			if e.BooksLimit > 0 && len(bs) > e.BooksLimit {
+				bs = bs[:e.BooksLimit]
+				details[i].BooksTruncated = true
+			}
+			details[i].Books = bs
+		}
+	}
+
+	if e.Tags {
+		tags, err := s.ListTagsByAuthors(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range details {
+			details[i].Tags = tags[details[i].ID]
+		}
+	}
+	return details, nil
+}
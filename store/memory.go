@@ -0,0 +1,527 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/audit"
+	"github.com/sters/try-mysql-partitioning/events"
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/outbox"
+)
+
+type linkKey struct {
+	ownerID, tagID int64
+}
+
+// Memory is a Store backed by maps. It follows the MySQL schema's rules:
+// ids are assigned in order, tag names and links are unique, and there are
+// no foreign keys, so links may point at rows that no longer exist.
+type Memory struct {
+	mu sync.Mutex
+
+	authors    map[int64]models.Author
+	books      map[int64]models.Book
+	tags       map[int64]models.Tag
+	bookTags   map[linkKey]models.BookTag
+	authorTags map[linkKey]models.AuthorTag
+	changes    []outbox.Event
+	auditLog   []audit.Entry
+	bookEvents []models.BookEvent
+
+	lastAuthorID, lastBookID, lastTagID int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		authors:    make(map[int64]models.Author),
+		books:      make(map[int64]models.Book),
+		tags:       make(map[int64]models.Tag),
+		bookTags:   make(map[linkKey]models.BookTag),
+		authorTags: make(map[linkKey]models.AuthorTag),
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Authors
+
+func (m *Memory) ListAuthors(ctx context.Context, limit, offset int) ([]models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var authors []models.Author
+	for _, id := range sortedIDs(m.authors) {
+		authors = append(authors, m.authors[id])
+	}
+	return page(authors, limit, offset), nil
+}
+
+func (m *Memory) GetAuthor(ctx context.Context, id int64) (models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.authors[id]
+	if !ok {
+		return a, ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *Memory) CreateAuthor(ctx context.Context, c Change, name string) (models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastAuthorID++
+	a := models.Author{ID: m.lastAuthorID, Name: name, CreatedAt: now()}
+	m.authors[a.ID] = a
+	m.record(c, "author", a.ID, "author.created", "author", "create", nil, a)
+	return a, nil
+}
+
+func (m *Memory) UpdateAuthor(ctx context.Context, c Change, id int64, name string) (models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.authors[id]
+	if !ok {
+		return before, ErrNotFound
+	}
+	after := before
+	after.Name = name
+	m.authors[id] = after
+	m.record(c, "author", id, "author.updated", "author", "update", before, after)
+	return after, nil
+}
+
+func (m *Memory) DeleteAuthor(ctx context.Context, c Change, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.authors[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.authors, id)
+	m.record(c, "author", id, "author.deleted", "author", "delete", before, nil)
+	return nil
+}
+
+// Books
+
+func (m *Memory) ListBooks(ctx context.Context, limit, offset int) ([]models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var books []models.Book
+	for _, id := range sortedIDs(m.books) {
+		books = append(books, m.books[id])
+	}
+	return page(books, limit, offset), nil
+}
+
+func (m *Memory) GetBook(ctx context.Context, id int64) (models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.books[id]
+	if !ok {
+		return b, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *Memory) CreateBook(ctx context.Context, c Change, title string, authorID int64) (models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastBookID++
+	b := models.Book{ID: m.lastBookID, Title: title, AuthorID: authorID, CreatedAt: now()}
+	m.books[b.ID] = b
+	m.record(c, "book", b.ID, "book.created", "book", "create", nil, b)
+	return b, nil
+}
+
+func (m *Memory) UpdateBook(ctx context.Context, c Change, id int64, title string, authorID int64) (models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.books[id]
+	if !ok {
+		return before, ErrNotFound
+	}
+	after := before
+	after.Title = title
+	after.AuthorID = authorID
+	m.books[id] = after
+	m.record(c, "book", id, "book.updated", "book", "update", before, after)
+	return after, nil
+}
+
+func (m *Memory) DeleteBook(ctx context.Context, c Change, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.books[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.books, id)
+	m.record(c, "book", id, "book.deleted", "book", "delete", before, nil)
+	return nil
+}
+
+// Tags
+
+func (m *Memory) ListTags(ctx context.Context) ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tags []models.Tag
+	for _, id := range sortedIDs(m.tags) {
+		tags = append(tags, m.tags[id])
+	}
+	return tags, nil
+}
+
+func (m *Memory) GetTag(ctx context.Context, id int64) (models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return t, ErrNotFound
+	}
+	return t, nil
+}
+
+func (m *Memory) CreateTag(ctx context.Context, c Change, name string) (models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tags {
+		if t.Name == name {
+			return models.Tag{}, ErrDuplicate
+		}
+	}
+
+	m.lastTagID++
+	t := models.Tag{ID: m.lastTagID, Name: name}
+	m.tags[t.ID] = t
+	m.record(c, "tag", t.ID, "tag.created", "tag", "create", nil, t)
+	return t, nil
+}
+
+func (m *Memory) DeleteTag(ctx context.Context, c Change, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before, ok := m.tags[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.tags, id)
+	m.record(c, "tag", id, "tag.deleted", "tag", "delete", before, nil)
+	return nil
+}
+
+// Tag links
+
+func (m *Memory) ListBookTags(ctx context.Context, bookID int64) ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tags []models.Tag
+	for _, id := range sortedIDs(m.tags) {
+		if _, ok := m.bookTags[linkKey{bookID, id}]; ok {
+			tags = append(tags, m.tags[id])
+		}
+	}
+	return tags, nil
+}
+
+func (m *Memory) AddBookTag(ctx context.Context, c Change, bookID, tagID int64) (models.BookTag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := linkKey{bookID, tagID}
+	if _, ok := m.bookTags[key]; ok {
+		return models.BookTag{}, ErrDuplicate
+	}
+	link := models.BookTag{BookID: bookID, TagID: tagID, CreatedAt: now()}
+	m.bookTags[key] = link
+	m.record(c, "book", bookID, "book_tag.added", "book_tag", "create", nil, link)
+	return link, nil
+}
+
+func (m *Memory) RemoveBookTag(ctx context.Context, c Change, bookID, tagID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := linkKey{bookID, tagID}
+	link, ok := m.bookTags[key]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.bookTags, key)
+	m.record(c, "book", bookID, "book_tag.removed", "book_tag", "delete", link, nil)
+	return nil
+}
+
+func (m *Memory) ListAuthorTags(ctx context.Context, authorID int64) ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tags []models.Tag
+	for _, id := range sortedIDs(m.tags) {
+		if _, ok := m.authorTags[linkKey{authorID, id}]; ok {
+			tags = append(tags, m.tags[id])
+		}
+	}
+	return tags, nil
+}
+
+func (m *Memory) AddAuthorTag(ctx context.Context, c Change, authorID, tagID int64) (models.AuthorTag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := linkKey{authorID, tagID}
+	if _, ok := m.authorTags[key]; ok {
+		return models.AuthorTag{}, ErrDuplicate
+	}
+	link := models.AuthorTag{AuthorID: authorID, TagID: tagID, CreatedAt: now()}
+	m.authorTags[key] = link
+	m.record(c, "author", authorID, "author_tag.added", "author_tag", "create", nil, link)
+	return link, nil
+}
+
+func (m *Memory) RemoveAuthorTag(ctx context.Context, c Change, authorID, tagID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := linkKey{authorID, tagID}
+	link, ok := m.authorTags[key]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.authorTags, key)
+	m.record(c, "author", authorID, "author_tag.removed", "author_tag", "delete", link, nil)
+	return nil
+}
+
+// Relations
+
+func (m *Memory) GetAuthorsByIDs(ctx context.Context, ids []int64) (map[int64]models.Author, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	authors := make(map[int64]models.Author, len(ids))
+	for _, id := range ids {
+		if a, ok := m.authors[id]; ok {
+			authors[id] = a
+		}
+	}
+	return authors, nil
+}
+
+func (m *Memory) ListBooksByAuthors(ctx context.Context, authorIDs []int64, perAuthor int) (map[int64][]models.Book, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := make(map[int64]bool, len(authorIDs))
+	for _, id := range authorIDs {
+		wanted[id] = true
+	}
+
+	books := make(map[int64][]models.Book)
+	for _, id := range sortedIDs(m.books) {
+		b := m.books[id]
+		if wanted[b.AuthorID] && (perAuthor <= 0 || len(books[b.AuthorID]) < perAuthor) {
+			books[b.AuthorID] = append(books[b.AuthorID], b)
+		}
+	}
+	return books, nil
+}
+
+func (m *Memory) ListTagsByBooks(ctx context.Context, bookIDs []int64) (map[int64][]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := make(map[int64][]models.Tag)
+	for _, bookID := range bookIDs {
+		if _, done := tags[bookID]; done {
+			continue
+		}
+		for _, id := range sortedIDs(m.tags) {
+			if _, ok := m.bookTags[linkKey{bookID, id}]; ok {
+				tags[bookID] = append(tags[bookID], m.tags[id])
+			}
+		}
+	}
+	return tags, nil
+}
+
+func (m *Memory) ListTagsByAuthors(ctx context.Context, authorIDs []int64) (map[int64][]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := make(map[int64][]models.Tag)
+	for _, authorID := range authorIDs {
+		if _, done := tags[authorID]; done {
+			continue
+		}
+		for _, id := range sortedIDs(m.tags) {
+			if _, ok := m.authorTags[linkKey{authorID, id}]; ok {
+				tags[authorID] = append(tags[authorID], m.tags[id])
+			}
+		}
+	}
+	return tags, nil
+}
+
+// Changes and events
+
+func (m *Memory) ListChanges(ctx context.Context, since int64, limit int) ([]outbox.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evs := []outbox.Event{}
+	for _, e := range m.changes {
+		if e.ID > since && len(evs) < limit {
+			evs = append(evs, e)
+		}
+	}
+	return evs, nil
+}
+
+func (m *Memory) ListAudit(ctx context.Context, f audit.Filter) ([]audit.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []audit.Entry{}
+	// Newest first; entries are appended in time order
+	for i := len(m.auditLog) - 1; i >= 0 && len(entries) < f.Limit; i-- {
+		e := m.auditLog[i]
+		if e.CreatedAt.Before(f.From) || !e.CreatedAt.Before(f.To) {
+			continue
+		}
+		if f.Entity != "" && e.Entity != f.Entity {
+			continue
+		}
+		if f.EntityID != 0 && e.EntityID != f.EntityID {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (m *Memory) AddBookEvents(ctx context.Context, evs []models.BookEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bookEvents = append(m.bookEvents, evs...)
+	return nil
+}
+
+func (m *Memory) BookEventSummary(ctx context.Context, bookID int64, from, to time.Time) (events.Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := events.Summary{BookID: bookID, From: from, To: to, Daily: []events.DailyCount{}}
+	daily := make(map[string]*events.DailyCount)
+	for _, e := range m.bookEvents {
+		if e.BookID != bookID || e.CreatedAt.Before(from) || !e.CreatedAt.Before(to) {
+			continue
+		}
+		date := e.CreatedAt.Format("2006-01-02")
+		d, ok := daily[date]
+		if !ok {
+			d = &events.DailyCount{Date: date}
+			daily[date] = d
+		}
+		switch e.Type {
+		case events.TypeView:
+			d.Views++
+			s.Views++
+		case events.TypePurchase:
+			d.Purchases++
+			s.Purchases++
+		}
+	}
+
+	dates := make([]string, 0, len(daily))
+	for date := range daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		s.Daily = append(s.Daily, *daily[date])
+	}
+	return s, nil
+}
+
+// record appends the outbox event and audit entry for a change. Callers
+// hold m.mu.
+func (m *Memory) record(c Change, aggregate string, aggregateID int64, eventType string,
+	entity, action string, before, after interface{}) {
+	payload := after
+	if payload == nil {
+		payload = before
+	}
+	ts := now()
+
+	m.changes = append(m.changes, outbox.Event{
+		ID:            int64(len(m.changes) + 1),
+		AggregateType: aggregate,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       mustJSON(payload),
+		CreatedAt:     ts,
+	})
+
+	e := c.entry(entity, aggregateID, action)
+	e.ID = int64(len(m.auditLog) + 1)
+	e.Before = mustJSON(before)
+	e.After = mustJSON(after)
+	e.CreatedAt = ts
+	m.auditLog = append(m.auditLog, e)
+}
+
+// now matches DATETIME's second precision.
+func now() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func sortedIDs[V any](m map[int64]V) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
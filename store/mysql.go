@@ -0,0 +1,530 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/sters/try-mysql-partitioning/audit"
+	"github.com/sters/try-mysql-partitioning/db"
+	"github.com/sters/try-mysql-partitioning/events"
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/outbox"
+)
+
+// MySQL reads through db.Reader, so a context carrying a recent write (see
+// db.WithLastWrite) keeps reads on the primary, and writes in transactions
+// on db.Writer.
+type MySQL struct{}
+
+func NewMySQL() *MySQL {
+	return &MySQL{}
+}
+
+func (s *MySQL) Ping(ctx context.Context) error {
+	return db.Writer().PingContext(ctx)
+}
+
+// Authors
+
+func (s *MySQL) ListAuthors(ctx context.Context, limit, offset int) ([]models.Author, error) {
+	rows, err := db.Reader(ctx).QueryContext(ctx, "SELECT id, name, created_at FROM authors ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var authors []models.Author
+	for rows.Next() {
+		var a models.Author
+		if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		authors = append(authors, a)
+	}
+	return authors, rows.Err()
+}
+
+func (s *MySQL) GetAuthor(ctx context.Context, id int64) (models.Author, error) {
+	var a models.Author
+	err := db.Reader(ctx).QueryRowContext(ctx, "SELECT id, name, created_at FROM authors WHERE id = ?", id).
+		Scan(&a.ID, &a.Name, &a.CreatedAt)
+	return a, notFound(err)
+}
+
+// lockAuthor reads an author inside tx and locks the row until commit.
+func lockAuthor(ctx context.Context, tx *sql.Tx, id int64) (models.Author, error) {
+	var a models.Author
+	err := tx.QueryRowContext(ctx, "SELECT id, name, created_at FROM authors WHERE id = ? FOR UPDATE", id).
+		Scan(&a.ID, &a.Name, &a.CreatedAt)
+	return a, notFound(err)
+}
+
+func (s *MySQL) CreateAuthor(ctx context.Context, c Change, name string) (models.Author, error) {
+	// DATETIME has second precision, so truncate before insert to avoid a re-SELECT
+	now := time.Now().Truncate(time.Second)
+	var a models.Author
+	err := inTx(ctx, "CreateAuthor", func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "INSERT INTO authors (name, created_at) VALUES (?, ?)", name, now)
+		if err != nil {
+			return err
+		}
+
+		id, _ := result.LastInsertId()
+		a = models.Author{ID: id, Name: name, CreatedAt: now}
+		return record(ctx, tx, c, "author", id, "author.created", "author", "create", nil, a)
+	})
+	return a, err
+}
+
+func (s *MySQL) UpdateAuthor(ctx context.Context, c Change, id int64, name string) (models.Author, error) {
+	var after models.Author
+	err := inTx(ctx, "UpdateAuthor", func(tx *sql.Tx) error {
+		before, err := lockAuthor(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE authors SET name = ? WHERE id = ?", name, id); err != nil {
+			return err
+		}
+
+		after = before
+		after.Name = name
+		return record(ctx, tx, c, "author", id, "author.updated", "author", "update", before, after)
+	})
+	return after, err
+}
+
+func (s *MySQL) DeleteAuthor(ctx context.Context, c Change, id int64) error {
+	return inTx(ctx, "DeleteAuthor", func(tx *sql.Tx) error {
+		before, err := lockAuthor(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM authors WHERE id = ?", id); err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "author", id, "author.deleted", "author", "delete", before, nil)
+	})
+}
+
+// Books
+
+func (s *MySQL) ListBooks(ctx context.Context, limit, offset int) ([]models.Book, error) {
+	rows, err := db.Reader(ctx).QueryContext(ctx, "SELECT id, title, author_id, created_at FROM books ORDER BY id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		var b models.Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
+
+func (s *MySQL) GetBook(ctx context.Context, id int64) (models.Book, error) {
+	var b models.Book
+	err := db.Reader(ctx).QueryRowContext(ctx, "SELECT id, title, author_id, created_at FROM books WHERE id = ?", id).
+		Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt)
+	return b, notFound(err)
+}
+
+// lockBook reads a book inside tx and locks the row until commit.
+func lockBook(ctx context.Context, tx *sql.Tx, id int64) (models.Book, error) {
+	var b models.Book
+	err := tx.QueryRowContext(ctx, "SELECT id, title, author_id, created_at FROM books WHERE id = ? FOR UPDATE", id).
+		Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt)
+	return b, notFound(err)
+}
+
+func (s *MySQL) CreateBook(ctx context.Context, c Change, title string, authorID int64) (models.Book, error) {
+	now := time.Now().Truncate(time.Second)
+	var b models.Book
+	err := inTx(ctx, "CreateBook", func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "INSERT INTO books (title, author_id, created_at) VALUES (?, ?, ?)",
+			title, authorID, now)
+		if err != nil {
+			return err
+		}
+
+		id, _ := result.LastInsertId()
+		b = models.Book{ID: id, Title: title, AuthorID: authorID, CreatedAt: now}
+		return record(ctx, tx, c, "book", id, "book.created", "book", "create", nil, b)
+	})
+	return b, err
+}
+
+func (s *MySQL) UpdateBook(ctx context.Context, c Change, id int64, title string, authorID int64) (models.Book, error) {
+	var after models.Book
+	err := inTx(ctx, "UpdateBook", func(tx *sql.Tx) error {
+		before, err := lockBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE books SET title = ?, author_id = ? WHERE id = ?", title, authorID, id); err != nil {
+			return err
+		}
+
+		after = before
+		after.Title = title
+		after.AuthorID = authorID
+		return record(ctx, tx, c, "book", id, "book.updated", "book", "update", before, after)
+	})
+	return after, err
+}
+
+func (s *MySQL) DeleteBook(ctx context.Context, c Change, id int64) error {
+	return inTx(ctx, "DeleteBook", func(tx *sql.Tx) error {
+		before, err := lockBook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM books WHERE id = ?", id); err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "book", id, "book.deleted", "book", "delete", before, nil)
+	})
+}
+
+// Tags
+
+func (s *MySQL) ListTags(ctx context.Context) ([]models.Tag, error) {
+	rows, err := db.Reader(ctx).QueryContext(ctx, "SELECT id, name FROM tags ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+func (s *MySQL) GetTag(ctx context.Context, id int64) (models.Tag, error) {
+	var t models.Tag
+	err := db.Reader(ctx).QueryRowContext(ctx, "SELECT id, name FROM tags WHERE id = ?", id).
+		Scan(&t.ID, &t.Name)
+	return t, notFound(err)
+}
+
+func (s *MySQL) CreateTag(ctx context.Context, c Change, name string) (models.Tag, error) {
+	var t models.Tag
+	err := inTx(ctx, "CreateTag", func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?)", name)
+		if err != nil {
+			return err
+		}
+
+		id, _ := result.LastInsertId()
+		t = models.Tag{ID: id, Name: name}
+		return record(ctx, tx, c, "tag", id, "tag.created", "tag", "create", nil, t)
+	})
+	return t, err
+}
+
+func (s *MySQL) DeleteTag(ctx context.Context, c Change, id int64) error {
+	return inTx(ctx, "DeleteTag", func(tx *sql.Tx) error {
+		var before models.Tag
+		err := tx.QueryRowContext(ctx, "SELECT id, name FROM tags WHERE id = ? FOR UPDATE", id).
+			Scan(&before.ID, &before.Name)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", id); err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "tag", id, "tag.deleted", "tag", "delete", before, nil)
+	})
+}
+
+// Tag links
+
+func (s *MySQL) ListBookTags(ctx context.Context, bookID int64) ([]models.Tag, error) {
+	rows, err := db.Reader(ctx).QueryContext(ctx, `
+		SELECT t.id, t.name FROM tags t
+		INNER JOIN book_tags bt ON t.id = bt.tag_id
+		WHERE bt.book_id = ?
+		ORDER BY t.id
+	`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+func (s *MySQL) AddBookTag(ctx context.Context, c Change, bookID, tagID int64) (models.BookTag, error) {
+	link := models.BookTag{BookID: bookID, TagID: tagID, CreatedAt: time.Now().Truncate(time.Second)}
+	err := inTx(ctx, "AddBookTag", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO book_tags (book_id, tag_id, created_at) VALUES (?, ?, ?)",
+			link.BookID, link.TagID, link.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "book", bookID, "book_tag.added", "book_tag", "create", nil, link)
+	})
+	return link, err
+}
+
+func (s *MySQL) RemoveBookTag(ctx context.Context, c Change, bookID, tagID int64) error {
+	return inTx(ctx, "RemoveBookTag", func(tx *sql.Tx) error {
+		var link models.BookTag
+		err := tx.QueryRowContext(ctx,
+			"SELECT book_id, tag_id, created_at FROM book_tags WHERE book_id = ? AND tag_id = ? FOR UPDATE", bookID, tagID).
+			Scan(&link.BookID, &link.TagID, &link.CreatedAt)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM book_tags WHERE book_id = ? AND tag_id = ?", bookID, tagID); err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "book", bookID, "book_tag.removed", "book_tag", "delete", link, nil)
+	})
+}
+
+func (s *MySQL) ListAuthorTags(ctx context.Context, authorID int64) ([]models.Tag, error) {
+	rows, err := db.Reader(ctx).QueryContext(ctx, `
+		SELECT t.id, t.name FROM tags t
+		INNER JOIN author_tags at ON t.id = at.tag_id
+		WHERE at.author_id = ?
+		ORDER BY t.id
+	`, authorID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+func (s *MySQL) AddAuthorTag(ctx context.Context, c Change, authorID, tagID int64) (models.AuthorTag, error) {
+	link := models.AuthorTag{AuthorID: authorID, TagID: tagID, CreatedAt: time.Now().Truncate(time.Second)}
+	err := inTx(ctx, "AddAuthorTag", func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO author_tags (author_id, tag_id, created_at) VALUES (?, ?, ?)",
+			link.AuthorID, link.TagID, link.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "author", authorID, "author_tag.added", "author_tag", "create", nil, link)
+	})
+	return link, err
+}
+
+func (s *MySQL) RemoveAuthorTag(ctx context.Context, c Change, authorID, tagID int64) error {
+	return inTx(ctx, "RemoveAuthorTag", func(tx *sql.Tx) error {
+		var link models.AuthorTag
+		err := tx.QueryRowContext(ctx,
+			"SELECT author_id, tag_id, created_at FROM author_tags WHERE author_id = ? AND tag_id = ? FOR UPDATE", authorID, tagID).
+			Scan(&link.AuthorID, &link.TagID, &link.CreatedAt)
+		if err != nil {
+			return notFound(err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM author_tags WHERE author_id = ? AND tag_id = ?", authorID, tagID); err != nil {
+			return err
+		}
+		return record(ctx, tx, c, "author", authorID, "author_tag.removed", "author_tag", "delete", link, nil)
+	})
+}
+
+// Relations
+
+// inBatchSize caps the number of ids in one IN list.
+const inBatchSize = 1000
+
+func (s *MySQL) GetAuthorsByIDs(ctx context.Context, ids []int64) (map[int64]models.Author, error) {
+	authors := make(map[int64]models.Author, len(ids))
+	err := inBatches(ids, func(in string, args []interface{}) error {
+		rows, err := db.Reader(ctx).QueryContext(ctx, "SELECT id, name, created_at FROM authors WHERE id IN ("+in+")", args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var a models.Author
+			if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt); err != nil {
+				return err
+			}
+			authors[a.ID] = a
+		}
+		return rows.Err()
+	})
+	return authors, err
+}
+
+func (s *MySQL) ListBooksByAuthors(ctx context.Context, authorIDs []int64, perAuthor int) (map[int64][]models.Book, error) {
+	books := make(map[int64][]models.Book)
+	err := inBatches(authorIDs, func(in string, args []interface{}) error {
+		query := "SELECT id, title, author_id, created_at FROM books WHERE author_id IN (" + in + ") ORDER BY author_id, id"
+		if perAuthor > 0 {
+			// Number each author's books so one query can cap them all
+			query = `
+				SELECT id, title, author_id, created_at FROM (
+					SELECT id, title, author_id, created_at,
+					       ROW_NUMBER() OVER (PARTITION BY author_id ORDER BY id) AS rn
+					FROM books WHERE author_id IN (` + in + `)
+				) b
+				WHERE rn <= ?
+				ORDER BY author_id, id`
+			args = append(args, perAuthor)
+		}
+
+		rows, err := db.Reader(ctx).QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var b models.Book
+			if err := rows.Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt); err != nil {
+				return err
+			}
+			books[b.AuthorID] = append(books[b.AuthorID], b)
+		}
+		return rows.Err()
+	})
+	return books, err
+}
+
+func (s *MySQL) ListTagsByBooks(ctx context.Context, bookIDs []int64) (map[int64][]models.Tag, error) {
+	return listTagsBy(ctx, "book_tags", "book_id", bookIDs)
+}
+
+func (s *MySQL) ListTagsByAuthors(ctx context.Context, authorIDs []int64) (map[int64][]models.Tag, error) {
+	return listTagsBy(ctx, "author_tags", "author_id", authorIDs)
+}
+
+// listTagsBy loads the tags linked through table, grouped by its owner
+// column.
+func listTagsBy(ctx context.Context, table, ownerColumn string, ids []int64) (map[int64][]models.Tag, error) {
+	tags := make(map[int64][]models.Tag)
+	err := inBatches(ids, func(in string, args []interface{}) error {
+		rows, err := db.Reader(ctx).QueryContext(ctx, `
+			SELECT l.`+ownerColumn+`, t.id, t.name FROM `+table+` l
+			INNER JOIN tags t ON t.id = l.tag_id
+			WHERE l.`+ownerColumn+` IN (`+in+`)
+			ORDER BY l.`+ownerColumn+`, t.id
+		`, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var owner int64
+			var t models.Tag
+			if err := rows.Scan(&owner, &t.ID, &t.Name); err != nil {
+				return err
+			}
+			tags[owner] = append(tags[owner], t)
+		}
+		return rows.Err()
+	})
+	return tags, err
+}
+
+// inBatches calls fn with placeholders and arguments for each run of up to
+// inBatchSize distinct ids.
+func inBatches(ids []int64, fn func(in string, args []interface{}) error) error {
+	ids = distinct(ids)
+	for start := 0; start < len(ids); start += inBatchSize {
+		end := start + inBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		args := make([]interface{}, end-start)
+		for i, id := range ids[start:end] {
+			args[i] = id
+		}
+		if err := fn(strings.TrimSuffix(strings.Repeat("?,", len(args)), ","), args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func distinct(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Changes and events
+
+// ListChanges reads the primary: a replica applies transactions in their
+// commit order after a delay of its own, which outbox.SettleWindow does not
+// cover.
+func (s *MySQL) ListChanges(ctx context.Context, since int64, limit int) ([]outbox.Event, error) {
+	return outbox.List(ctx, db.Writer(), since, limit)
+}
+
+func (s *MySQL) ListAudit(ctx context.Context, f audit.Filter) ([]audit.Entry, error) {
+	return audit.List(ctx, db.Reader(ctx), f)
+}
+
+func (s *MySQL) AddBookEvents(ctx context.Context, evs []models.BookEvent) error {
+	// A multi-row INSERT is atomic, so it can be retried like a transaction
+	return db.Retry(ctx, "AddBookEvents", func() error {
+		return events.Insert(ctx, db.Writer(), evs)
+	})
+}
+
+func (s *MySQL) BookEventSummary(ctx context.Context, bookID int64, from, to time.Time) (events.Summary, error) {
+	return events.Summarize(ctx, db.Reader(ctx), bookID, from, to)
+}
+
+// inTx runs fn in a transaction on the primary via db.InTx, which retries
+// deadlocks and lock wait timeouts. A duplicate key error anywhere in fn is
+// reported as ErrDuplicate.
+func inTx(ctx context.Context, op string, fn func(tx *sql.Tx) error) error {
+	err := db.InTx(ctx, op, fn)
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1062 {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// record enqueues the outbox event and writes the audit entry for a change
+// made in tx.
+func record(ctx context.Context, tx *sql.Tx, c Change, aggregate string, aggregateID int64, eventType string,
+	entity, action string, before, after interface{}) error {
+	payload := after
+	if payload == nil {
+		payload = before
+	}
+	if err := outbox.Enqueue(ctx, tx, aggregate, aggregateID, eventType, payload); err != nil {
+		return err
+	}
+	return audit.Record(ctx, tx, c.entry(entity, aggregateID, action), before, after)
+}
+
+func scanTags(rows *sql.Rows) ([]models.Tag, error) {
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/shard"
+)
+
+// Sharded reads books from the application-sharded layout, where each shard
+// schema holds the books of the authors its router maps to it. Lookups by
+// author go to one shard; the others scatter to every shard and merge the
+// results in id order.
+type Sharded struct {
+	Router *shard.Router
+}
+
+func NewSharded(router *shard.Router) *Sharded {
+	return &Sharded{Router: router}
+}
+
+const bookColumns = "id, title, author_id, created_at"
+
+// ListBooksByAuthor reads from the author's shard only.
+func (s *Sharded) ListBooksByAuthor(ctx context.Context, authorID int64) ([]models.Book, error) {
+	rows, err := s.Router.ForAuthor(authorID).QueryContext(ctx,
+		"SELECT "+bookColumns+" FROM books WHERE author_id = ? ORDER BY id", authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanBooks(rows)
+}
+
+// GetBook has no shard key, so it asks every shard.
+func (s *Sharded) GetBook(ctx context.Context, id int64) (models.Book, error) {
+	books, err := s.gather(ctx, "SELECT "+bookColumns+" FROM books WHERE id = ?", id)
+	if err != nil {
+		return models.Book{}, err
+	}
+	if len(books) == 0 {
+		return models.Book{}, ErrNotFound
+	}
+	return books[0], nil
+}
+
+// ListBooksCreatedBetween returns the books created in [from, to) on all
+// shards.
+func (s *Sharded) ListBooksCreatedBetween(ctx context.Context, from, to time.Time) ([]models.Book, error) {
+	return s.gather(ctx, "SELECT "+bookColumns+" FROM books WHERE created_at >= ? AND created_at < ?", from, to)
+}
+
+// CountBooks sums the per-shard counts.
+func (s *Sharded) CountBooks(ctx context.Context) (int64, error) {
+	return s.Router.SumAll(ctx, "SELECT COUNT(*) FROM books")
+}
+
+func (s *Sharded) gather(ctx context.Context, query string, args ...interface{}) ([]models.Book, error) {
+	perShard := make([][]models.Book, len(s.Router.Shards))
+	err := s.Router.QueryAll(ctx, query, args, func(i int, rows *sql.Rows) error {
+		var err error
+		perShard[i], err = scanBooks(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var books []models.Book
+	for _, bs := range perShard {
+		books = append(books, bs...)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+	return books, nil
+}
+
+func scanBooks(rows *sql.Rows) ([]models.Book, error) {
+	var books []models.Book
+	for rows.Next() {
+		var b models.Book
+		if err := rows.Scan(&b.ID, &b.Title, &b.AuthorID, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		books = append(books, b)
+	}
+	return books, rows.Err()
+}
@@ -0,0 +1,111 @@
+// Package store is the data access layer behind the HTTP handlers. MySQL is
+// the real implementation; Memory keeps everything in maps so handlers can be
+// tested without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sters/try-mysql-partitioning/audit"
+	"github.com/sters/try-mysql-partitioning/events"
+	"github.com/sters/try-mysql-partitioning/models"
+	"github.com/sters/try-mysql-partitioning/outbox"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when an insert violates a unique key.
+	ErrDuplicate = errors.New("already exists")
+)
+
+// Change identifies who is making a write, for the audit log.
+type Change struct {
+	Actor     string
+	RequestID string
+}
+
+func (c Change) entry(entity string, id int64, action string) audit.Entry {
+	return audit.Entry{
+		Actor:     c.Actor,
+		RequestID: c.RequestID,
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+	}
+}
+
+// Every write below records an outbox event and an audit entry atomically
+// with the change itself.
+
+type AuthorStore interface {
+	ListAuthors(ctx context.Context, limit, offset int) ([]models.Author, error)
+	GetAuthor(ctx context.Context, id int64) (models.Author, error)
+	CreateAuthor(ctx context.Context, c Change, name string) (models.Author, error)
+	UpdateAuthor(ctx context.Context, c Change, id int64, name string) (models.Author, error)
+	DeleteAuthor(ctx context.Context, c Change, id int64) error
+}
+
+type BookStore interface {
+	ListBooks(ctx context.Context, limit, offset int) ([]models.Book, error)
+	GetBook(ctx context.Context, id int64) (models.Book, error)
+	CreateBook(ctx context.Context, c Change, title string, authorID int64) (models.Book, error)
+	UpdateBook(ctx context.Context, c Change, id int64, title string, authorID int64) (models.Book, error)
+	DeleteBook(ctx context.Context, c Change, id int64) error
+}
+
+type TagStore interface {
+	ListTags(ctx context.Context) ([]models.Tag, error)
+	GetTag(ctx context.Context, id int64) (models.Tag, error)
+	CreateTag(ctx context.Context, c Change, name string) (models.Tag, error)
+	DeleteTag(ctx context.Context, c Change, id int64) error
+}
+
+// TagLinkStore manages the book_tags and author_tags associations. Lists
+// return the linked tags ordered by id.
+type TagLinkStore interface {
+	ListBookTags(ctx context.Context, bookID int64) ([]models.Tag, error)
+	AddBookTag(ctx context.Context, c Change, bookID, tagID int64) (models.BookTag, error)
+	RemoveBookTag(ctx context.Context, c Change, bookID, tagID int64) error
+
+	ListAuthorTags(ctx context.Context, authorID int64) ([]models.Tag, error)
+	AddAuthorTag(ctx context.Context, c Change, authorID, tagID int64) (models.AuthorTag, error)
+	RemoveAuthorTag(ctx context.Context, c Change, authorID, tagID int64) error
+}
+
+// RelationStore loads the relations of many rows at once for ?expand=, one
+// IN-query per relation instead of a lookup per row. Ids without related
+// rows are absent from the returned maps; lists are ordered by id.
+type RelationStore interface {
+	GetAuthorsByIDs(ctx context.Context, ids []int64) (map[int64]models.Author, error)
+	// ListBooksByAuthors returns at most perAuthor books per author, or all
+	// of them if perAuthor <= 0.
+	ListBooksByAuthors(ctx context.Context, authorIDs []int64, perAuthor int) (map[int64][]models.Book, error)
+	ListTagsByBooks(ctx context.Context, bookIDs []int64) (map[int64][]models.Tag, error)
+	ListTagsByAuthors(ctx context.Context, authorIDs []int64) (map[int64][]models.Tag, error)
+}
+
+// ChangeStore reads back what the writes recorded.
+type ChangeStore interface {
+	ListChanges(ctx context.Context, since int64, limit int) ([]outbox.Event, error)
+	ListAudit(ctx context.Context, f audit.Filter) ([]audit.Entry, error)
+}
+
+type EventStore interface {
+	AddBookEvents(ctx context.Context, evs []models.BookEvent) error
+	BookEventSummary(ctx context.Context, bookID int64, from, to time.Time) (events.Summary, error)
+}
+
+// Store is everything the handlers need.
+type Store interface {
+	AuthorStore
+	BookStore
+	TagStore
+	TagLinkStore
+	RelationStore
+	ChangeStore
+	EventStore
+	Ping(ctx context.Context) error
+}
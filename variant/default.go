@@ -0,0 +1,98 @@
+package variant
+
+const scripts = "scripts/partition/"
+
+// Default describes the tables of this repository. Keep it in sync with
+// migrations/ and scripts/partition/.
+var Default = MustNew(
+	// Base tables (migrations/0001_initial_schema.up.sql)
+	Variant{Table: "authors", Base: "authors", Method: None, Group: GroupBase},
+	Variant{Table: "books", Base: "books", Method: None, Group: GroupBase},
+	Variant{Table: "tags", Base: "tags", Method: None, Group: GroupBase},
+	Variant{Table: "book_tags", Base: "book_tags", Method: None, Group: GroupBase},
+	Variant{Table: "author_tags", Base: "author_tags", Method: None, Group: GroupBase},
+
+	// Tables partitioned by partman
+	Variant{Table: "outbox", Base: "outbox", Method: Range, Key: "TO_DAYS(created_at)", Group: GroupManaged,
+		Description: "Change feed, daily partitions"},
+	Variant{Table: "audit_log", Base: "audit_log", Method: Range, Key: "TO_DAYS(created_at)", Group: GroupManaged,
+		Description: "Audit log, monthly partitions"},
+	Variant{Table: "book_events", Base: "book_events", Method: Range, Key: "TO_DAYS(created_at)", Group: GroupManaged,
+		Description: "View and purchase events, daily partitions"},
+
+	// HASH by id
+	Variant{Table: "books_hash", Base: "books", Method: Hash, Key: "id", Partitions: 8, Group: "hash",
+		Setup: scripts + "hash.sql", Description: "Even spread by id"},
+	Variant{Table: "authors_hash", Base: "authors", Method: Hash, Key: "id", Partitions: 4, Group: "hash",
+		Setup: scripts + "hash.sql"},
+	Variant{Table: "book_tags_hash", Base: "book_tags", Method: Hash, Key: "book_id", Partitions: 8, Group: "hash",
+		Setup: scripts + "hash.sql", Description: "Aligned with books_hash"},
+
+	// RANGE by year
+	Variant{Table: "books_range_year", Base: "books", Method: Range, Key: "YEAR(created_at)", Partitions: 7, Group: "range_year",
+		Setup: scripts + "range_by_year.sql", Description: "One partition per year plus pmax"},
+	Variant{Table: "book_tags_range_year", Base: "book_tags", Method: Range, Key: "YEAR(created_at)", Partitions: 7, Group: "range_year",
+		Setup: scripts + "range_by_year.sql"},
+
+	// RANGE by id
+	Variant{Table: "books_range_id", Base: "books", Method: Range, Key: "id", Partitions: 11, Group: "range_id",
+		Setup: scripts + "range_by_id.sql", Description: "100k ids per partition"},
+	Variant{Table: "book_tags_range_id", Base: "book_tags", Method: Range, Key: "book_id", Partitions: 11, Group: "range_id",
+		Setup: scripts + "range_by_id.sql"},
+
+	// LIST
+	Variant{Table: "books_list", Base: "books", Method: List, Key: "status", Extra: []string{"status"}, Partitions: 4, Group: "list",
+		Setup: scripts + "list.sql", Description: "Adds a random status column"},
+	Variant{Table: "author_tags_list", Base: "author_tags", Method: List, Key: "region", Extra: []string{"region"}, Partitions: 10, Group: "list",
+		Setup: scripts + "list.sql", Description: "Adds a random region column"},
+
+	// KEY
+	Variant{Table: "books_key", Base: "books", Method: Key, Key: "id", Partitions: 8, Group: "key",
+		Setup: scripts + "key.sql", Description: "KEY() on the primary key"},
+	Variant{Table: "book_tags_key", Base: "book_tags", Method: Key, Key: "book_id, tag_id", Partitions: 16, Group: "key",
+		Setup: scripts + "key.sql"},
+	Variant{Table: "author_tags_key", Base: "author_tags", Method: Key, Key: "author_id, tag_id", Partitions: 8, Group: "key",
+		Setup: scripts + "key.sql"},
+
+	// HASH / RANGE by author, compared with application sharding
+	Variant{Table: "books_hash_author", Base: "books", Method: Hash, Key: "author_id", Partitions: 8, Group: "hash_author",
+		Setup: scripts + "hash_by_author.sql", Description: "Same key as the shard router"},
+	Variant{Table: "book_tags_hash_bookid", Base: "book_tags", Method: Hash, Key: "book_id", Partitions: 8, Group: "hash_author",
+		Setup: scripts + "hash_by_author.sql"},
+	Variant{Table: "books_range_author", Base: "books", Method: Range, Key: "author_id", Partitions: 11, Group: "range_author",
+		Setup: scripts + "range_by_author.sql"},
+	Variant{Table: "book_tags_range_bookid", Base: "book_tags", Method: Range, Key: "book_id", Partitions: 11, Group: "range_author",
+		Setup: scripts + "range_by_author.sql"},
+
+	// Partition count
+	Variant{Table: "books_hash_8", Base: "books", Method: Hash, Key: "id", Partitions: 8, Group: "hash_many",
+		Setup: scripts + "many_partitions.sql"},
+	Variant{Table: "books_hash_100", Base: "books", Method: Hash, Key: "id", Partitions: 100, Group: "hash_many",
+		Setup: scripts + "many_partitions.sql"},
+	Variant{Table: "books_hash_1000", Base: "books", Method: Hash, Key: "id", Partitions: 1000, Group: "hash_many",
+		Setup: scripts + "many_partitions.sql"},
+	Variant{Table: "books_hash_8000", Base: "books", Method: Hash, Key: "id", Partitions: 8000, Group: "hash_many",
+		Setup: scripts + "many_partitions.sql"},
+	Variant{Table: "books_key_8000", Base: "books", Method: Key, Key: "id", Partitions: 8000, Group: "hash_many",
+		Setup: scripts + "many_partitions.sql"},
+	Variant{Table: "books_range_8", Base: "books", Method: Range, Key: "id", Partitions: 8, Group: "range_many",
+		Setup: scripts + "range_many_partitions.sql"},
+	Variant{Table: "books_range_100", Base: "books", Method: Range, Key: "id", Partitions: 100, Group: "range_many",
+		Setup: scripts + "range_many_partitions.sql"},
+	Variant{Table: "books_range_1000", Base: "books", Method: Range, Key: "id", Partitions: 1000, Group: "range_many",
+		Setup: scripts + "range_many_partitions.sql"},
+	Variant{Table: "books_range_8000", Base: "books", Method: Range, Key: "id", Partitions: 8000, Group: "range_many",
+		Setup: scripts + "range_many_partitions.sql"},
+
+	// Index vs partition
+	Variant{Table: "books_bare", Base: "books", Method: None, Group: "index_vs_partition",
+		Setup: scripts + "compare_index_vs_partition.sql", Description: "No secondary indexes, no partitions"},
+	Variant{Table: "books_part_no_idx", Base: "books", Method: Range, Key: "YEAR(created_at)", Partitions: 7, Group: "index_vs_partition",
+		Setup: scripts + "compare_index_vs_partition.sql"},
+	Variant{Table: "books_part_with_idx", Base: "books", Method: Range, Key: "YEAR(created_at)", Partitions: 7, Group: "index_vs_partition",
+		Setup: scripts + "compare_index_vs_partition.sql"},
+	Variant{Table: "books_part_author_no_idx", Base: "books", Method: Range, Key: "author_id", Partitions: 11, Group: "index_vs_partition",
+		Setup: scripts + "compare_index_vs_partition.sql"},
+	Variant{Table: "books_part_author_with_idx", Base: "books", Method: Range, Key: "author_id", Partitions: 11, Group: "index_vs_partition",
+		Setup: scripts + "compare_index_vs_partition.sql"},
+)
@@ -0,0 +1,275 @@
+// Package variant describes the tables this project compares: the base
+// tables, the partitioned copies made by scripts/partition, and the tables
+// that partman manages. Tools and the API enumerate and validate tables
+// through Default instead of keeping their own lists.
+package variant
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Method string
+
+const (
+	None  Method = "NONE"
+	Range Method = "RANGE"
+	Hash  Method = "HASH"
+	Key   Method = "KEY"
+	List  Method = "LIST"
+)
+
+// Groups that are not comparison types.
+const (
+	// GroupBase holds the unpartitioned base tables.
+	GroupBase = "base"
+	// GroupManaged holds tables created by migrations whose partitions are
+	// added and dropped by partman.
+	GroupManaged = "managed"
+)
+
+// Variant is one table.
+type Variant struct {
+	Table string `json:"table"`
+	// Base is the unpartitioned table this is a copy of, or Table itself.
+	Base   string `json:"base"`
+	Method Method `json:"method"`
+	// Key is the partitioning expression, e.g. "YEAR(created_at)".
+	Key string `json:"key,omitempty"`
+	// Extra lists the columns the variant adds to its base table, e.g.
+	// "status" for the LIST variant of books.
+	Extra []string `json:"extra,omitempty"`
+	// Partitions is the number of partitions the setup script creates. For
+	// managed tables it changes over time and is 0 here.
+	Partitions int `json:"partitions,omitempty"`
+	// Group is the comparison type (cmd/compare -type) the variant belongs
+	// to; variants in a group are created by the same Setup script.
+	Group string `json:"group"`
+	// Setup is the script that creates and fills the table, relative to the
+	// repository root. Empty for base and managed tables.
+	Setup       string `json:"setup,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IsBase reports whether v is an unpartitioned base table.
+func (v Variant) IsBase() bool {
+	return v.Group == GroupBase
+}
+
+// Teardown returns the statement that removes the variant, or "" for base
+// and managed tables, which must never be dropped by a tool.
+func (v Variant) Teardown() string {
+	if v.Setup == "" {
+		return ""
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS `%s`", v.Table)
+}
+
+// Registry is an ordered, validated set of variants.
+type Registry struct {
+	variants []Variant
+	byTable  map[string]int
+}
+
+// New validates variants: table names are unique, methods known, every Base
+// is itself registered as a base table, and partitioned variants in a
+// comparison group have a setup script.
+func New(variants ...Variant) (*Registry, error) {
+	r := &Registry{byTable: make(map[string]int, len(variants))}
+	for _, v := range variants {
+		if v.Table == "" || v.Base == "" || v.Group == "" {
+			return nil, fmt.Errorf("variant %q: table, base and group are required", v.Table)
+		}
+		if _, ok := r.byTable[v.Table]; ok {
+			return nil, fmt.Errorf("variant %q registered twice", v.Table)
+		}
+		switch v.Method {
+		case None, Range, Hash, Key, List:
+		default:
+			return nil, fmt.Errorf("variant %q: unknown method %q", v.Table, v.Method)
+		}
+		if v.Group != GroupBase && v.Group != GroupManaged && v.Setup == "" {
+			return nil, fmt.Errorf("variant %q: setup script is required", v.Table)
+		}
+		r.byTable[v.Table] = len(r.variants)
+		r.variants = append(r.variants, v)
+	}
+
+	for _, v := range r.variants {
+		base, ok := r.Get(v.Base)
+		if !ok || !base.IsBase() && base.Table != v.Table {
+			return nil, fmt.Errorf("variant %q: base table %q is not registered", v.Table, v.Base)
+		}
+	}
+	return r, nil
+}
+
+// MustNew is New that panics, for package-level registries.
+func MustNew(variants ...Variant) *Registry {
+	r, err := New(variants...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// All returns every variant in registration order.
+func (r *Registry) All() []Variant {
+	return append([]Variant(nil), r.variants...)
+}
+
+func (r *Registry) Get(table string) (Variant, bool) {
+	i, ok := r.byTable[table]
+	if !ok {
+		return Variant{}, false
+	}
+	return r.variants[i], true
+}
+
+// Lookup is Get with an error naming the known tables.
+func (r *Registry) Lookup(table string) (Variant, error) {
+	v, ok := r.Get(table)
+	if !ok {
+		return Variant{}, fmt.Errorf("unknown table %q (known: %s)", table, strings.Join(r.Tables(), ", "))
+	}
+	return v, nil
+}
+
+// Require looks up table and checks that it is partitioned with one of
+// methods.
+func (r *Registry) Require(table string, methods ...Method) (Variant, error) {
+	v, err := r.Lookup(table)
+	if err != nil {
+		return v, err
+	}
+	for _, m := range methods {
+		if v.Method == m {
+			return v, nil
+		}
+	}
+	return v, fmt.Errorf("table %q is %s-partitioned, need %s", table, v.Method, joinMethods(methods))
+}
+
+// Tables returns every table name in registration order.
+func (r *Registry) Tables() []string {
+	tables := make([]string, len(r.variants))
+	for i, v := range r.variants {
+		tables[i] = v.Table
+	}
+	return tables
+}
+
+// Bases returns the unpartitioned base tables.
+func (r *Registry) Bases() []Variant {
+	return r.Group(GroupBase)
+}
+
+// Group returns the variants of one group.
+func (r *Registry) Group(group string) []Variant {
+	var out []Variant
+	for _, v := range r.variants {
+		if v.Group == group {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Groups returns the comparison groups, in registration order. The base
+// and managed groups are not included.
+func (r *Registry) Groups() []string {
+	var groups []string
+	seen := map[string]bool{GroupBase: true, GroupManaged: true}
+	for _, v := range r.variants {
+		if !seen[v.Group] {
+			seen[v.Group] = true
+			groups = append(groups, v.Group)
+		}
+	}
+	return groups
+}
+
+// HasGroup reports whether group is a comparison group.
+func (r *Registry) HasGroup(group string) bool {
+	for _, g := range r.Groups() {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup returns the setup scripts of a group, without duplicates.
+func (r *Registry) Setup(group string) []string {
+	var scripts []string
+	seen := make(map[string]bool)
+	for _, v := range r.Group(group) {
+		if v.Setup != "" && !seen[v.Setup] {
+			seen[v.Setup] = true
+			scripts = append(scripts, v.Setup)
+		}
+	}
+	return scripts
+}
+
+// Teardown returns the statements that drop a group's variants.
+func (r *Registry) Teardown(group string) []string {
+	var stmts []string
+	for _, v := range r.Group(group) {
+		if stmt := v.Teardown(); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// Expand replaces each {base} placeholder in query with the table of group
+// that copies that base, e.g. "{books}" becomes "books_hash" for group
+// "hash". It returns the variants used.
+func (r *Registry) Expand(group, query string) (string, []Variant, error) {
+	var used []Variant
+	var missing []string
+	for _, base := range r.Bases() {
+		placeholder := "{" + base.Table + "}"
+		if !strings.Contains(query, placeholder) {
+			continue
+		}
+		v, err := r.For(group, base.Table)
+		if err != nil {
+			missing = append(missing, base.Table)
+			continue
+		}
+		query = strings.ReplaceAll(query, placeholder, v.Table)
+		used = append(used, v)
+	}
+	if len(missing) > 0 {
+		return "", nil, fmt.Errorf("group %q has no variant of %s", group, strings.Join(missing, ", "))
+	}
+	if i := strings.Index(query, "{"); i >= 0 {
+		if j := strings.Index(query[i:], "}"); j >= 0 {
+			return "", nil, fmt.Errorf("unknown base table %s", query[i:i+j+1])
+		}
+	}
+	return query, used, nil
+}
+
+// For returns the variant of group that copies base. In groups with
+// several copies of one base, the first registered wins.
+func (r *Registry) For(group, base string) (Variant, error) {
+	for _, v := range r.variants {
+		if v.Group == group && v.Base == base {
+			return v, nil
+		}
+	}
+	return Variant{}, fmt.Errorf("group %q has no variant of %s", group, base)
+}
+
+func joinMethods(methods []Method) string {
+	names := make([]string, len(methods))
+	for i, m := range methods {
+		names[i] = string(m)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " or ")
+}
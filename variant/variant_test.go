@@ -0,0 +1,77 @@
+package variant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultSetupScriptsExist(t *testing.T) {
+	for _, v := range Default.All() {
+		if v.Setup == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("..", v.Setup)); err != nil {
+			t.Errorf("%s: %v", v.Table, err)
+		}
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	books := Variant{Table: "books", Base: "books", Method: None, Group: GroupBase}
+	for name, variants := range map[string][]Variant{
+		"duplicate":      {books, books},
+		"unknown method": {books, {Table: "b", Base: "books", Method: "SPLIT", Group: "g", Setup: "s.sql"}},
+		"unknown base":   {books, {Table: "b", Base: "nope", Method: Hash, Group: "g", Setup: "s.sql"}},
+		"no setup":       {books, {Table: "b", Base: "books", Method: Hash, Group: "g"}},
+	} {
+		if _, err := New(variants...); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	query, used, err := Default.Expand("hash", "SELECT * FROM {books} b JOIN {book_tags} bt ON b.id = bt.book_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SELECT * FROM books_hash b JOIN book_tags_hash bt ON b.id = bt.book_id" {
+		t.Errorf("query = %q", query)
+	}
+	if len(used) != 2 {
+		t.Errorf("used = %+v", used)
+	}
+
+	if _, _, err := Default.Expand("list", "SELECT * FROM {book_tags}"); err == nil {
+		t.Error("expected an error for a group without book_tags")
+	}
+	if _, _, err := Default.Expand("hash", "SELECT * FROM {nope}"); err == nil {
+		t.Error("expected an error for an unknown placeholder")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	if _, err := Default.Require("books_range_year", Range); err != nil {
+		t.Error(err)
+	}
+	if _, err := Default.Require("books_hash", Range, List); err == nil || !strings.Contains(err.Error(), "HASH") {
+		t.Errorf("err = %v, want a method mismatch", err)
+	}
+	if _, err := Default.Require("nope", Range); err == nil {
+		t.Error("expected an error for an unknown table")
+	}
+}
+
+func TestSetupAndTeardown(t *testing.T) {
+	if got := Default.Setup("hash"); len(got) != 1 || got[0] != "scripts/partition/hash.sql" {
+		t.Errorf("Setup(hash) = %q", got)
+	}
+	if got := Default.Teardown("range_year"); len(got) != 2 {
+		t.Errorf("Teardown(range_year) = %q", got)
+	}
+	if got := Default.Teardown(GroupManaged); len(got) != 0 {
+		t.Errorf("managed tables must not be dropped: %q", got)
+	}
+}